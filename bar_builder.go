@@ -0,0 +1,332 @@
+package tdx
+
+import (
+	"context"
+	"github.com/injoyai/tdx/protocol"
+	"sync"
+	"time"
+)
+
+type BarBuilderConfig struct {
+	Types []protocol.KlineType //需要生成的k线类型,支持1/5/15/30/60分钟和日线,默认全部
+	Delay time.Duration        //k线结束后等待延迟数据的时间,默认3秒
+}
+
+// NewBarBuilder 实时k线生成,根据实时的分时成交或者盘口,维护进行中的k线,k线完成时回调onClose
+// k线的时间和分组规则和 protocol.Trades.Klines 一致,例9:30:xx的成交属于9:31的1分钟k线,
+// 11:30和15:00的k线在收盘后(加上Delay)通过Tick完成
+func NewBarBuilder(cfg BarBuilderConfig, onClose func(code string, Type protocol.KlineType, k *protocol.Kline)) *BarBuilder {
+	if len(cfg.Types) == 0 {
+		cfg.Types = []protocol.KlineType{
+			protocol.TypeKlineMinute,
+			protocol.TypeKline5Minute,
+			protocol.TypeKline15Minute,
+			protocol.TypeKline30Minute,
+			protocol.TypeKline60Minute,
+			protocol.TypeKlineDay,
+		}
+	}
+	types := []protocol.KlineType(nil)
+	for _, v := range cfg.Types {
+		if barMinutes(v) > 0 {
+			types = append(types, v)
+		}
+	}
+	cfg.Types = types
+	if cfg.Delay <= 0 {
+		cfg.Delay = time.Second * 3
+	}
+	return &BarBuilder{
+		Config:  cfg,
+		onClose: onClose,
+		codes:   make(map[string]*barCode),
+	}
+}
+
+// BarBuilder 实时k线生成
+type BarBuilder struct {
+	Config  BarBuilderConfig
+	onClose func(code string, Type protocol.KlineType, k *protocol.Kline)
+	codes   map[string]*barCode
+	mu      sync.Mutex
+}
+
+type barCode struct {
+	bars   map[protocol.KlineType]*barState
+	date   time.Time //盘口累计数据的日期
+	volume int       //盘口的累计成交量(手)
+	amount float64   //盘口的累计成交额
+}
+
+type barState struct {
+	date   time.Time       //k线的日期(零点)
+	k      *protocol.Kline //进行中的k线,nil表示没有
+	idx    int             //进行中k线的结束分钟序号(1-240)
+	closed int             //最后完成的k线的结束分钟序号
+	last   protocol.Price  //最后完成的k线的收盘价
+}
+
+type barEvent struct {
+	code string
+	Type protocol.KlineType
+	k    *protocol.Kline
+}
+
+// AddTrade 添加实时的分时成交,ls需要按时间正序,例如 TradeTracker 的回调
+func (this *BarBuilder) AddTrade(code string, ls ...*protocol.Trade) {
+	code = protocol.AddPrefix(code)
+	this.do(func(emit func(barEvent)) {
+		c := this.getCode(code)
+		for _, v := range ls {
+			if v.Price <= 0 {
+				continue
+			}
+			for _, Type := range this.Config.Types {
+				this.add(code, Type, c.bar(Type), v.Time, v.Price, 0, int64(v.Volume), v.Price*protocol.Price(v.Volume)*100, emit)
+			}
+		}
+	})
+}
+
+// AddQuote 添加实时的盘口,t是盘口的时间,例如 QuoteFeed 的事件时间
+// 成交量是根据累计成交量的差值计算的,所以每个代码当天收到的第一个盘口只用于日线
+func (this *BarBuilder) AddQuote(q *protocol.Quote, t time.Time) {
+	if q == nil || q.K.Close <= 0 {
+		return
+	}
+	code := q.Exchange.String() + q.Code
+	this.do(func(emit func(barEvent)) {
+		c := this.getCode(code)
+
+		var volume int64
+		var amount protocol.Price
+		if day := barDate(t); c.date.Equal(day) {
+			volume = int64(q.TotalHand - c.volume)
+			amount = protocol.Price((q.Amount - c.amount) * 1000)
+		} else {
+			c.date = day
+		}
+		c.volume, c.amount = q.TotalHand, q.Amount
+
+		for _, Type := range this.Config.Types {
+			st := c.bar(Type)
+			this.add(code, Type, st, t, q.K.Close, q.K.Last, volume, amount, emit)
+			if Type == protocol.TypeKlineDay && st.k != nil {
+				//日线直接使用盘口的全天数据
+				st.k.Open, st.k.High, st.k.Low = q.K.Open, q.K.High, q.K.Low
+				st.k.Volume = int64(q.TotalHand)
+				st.k.Amount = protocol.Price(q.Amount * 1000)
+			}
+		}
+	})
+}
+
+// Tick 完成结束时间(加上Delay)早于now的k线,没有成交的时间段会生成平盘的k线
+func (this *BarBuilder) Tick(now time.Time) {
+	now = now.Add(-this.Config.Delay).In(protocol.Location)
+	day := barDate(now)
+	ended := protocol.ElapsedTradeMinutes(now)
+	this.do(func(emit func(barEvent)) {
+		for code, c := range this.codes {
+			for Type, st := range c.bars {
+				p := barMinutes(Type)
+				switch {
+				case st.date.IsZero():
+				case st.date.Before(day):
+					this.advance(code, Type, st, 240, emit)
+				case st.date.Equal(day):
+					this.advance(code, Type, st, ended/p*p, emit)
+				}
+			}
+		}
+	})
+}
+
+// Run 每秒执行一次Tick,直到ctx结束
+func (this *BarBuilder) Run(ctx context.Context) error {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-t.C:
+			this.Tick(now)
+		}
+	}
+}
+
+// Current 获取进行中的k线,返回的是副本,没有则返回nil
+func (this *BarBuilder) Current(code string, Type protocol.KlineType) *protocol.Kline {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	c, ok := this.codes[protocol.AddPrefix(code)]
+	if !ok {
+		return nil
+	}
+	st, ok := c.bars[Type]
+	if !ok || st.k == nil {
+		return nil
+	}
+	k := *st.k
+	return &k
+}
+
+// do 加锁执行,回调在解锁之后执行,避免在回调里调用Current等方法死锁
+func (this *BarBuilder) do(fn func(emit func(barEvent))) {
+	events := []barEvent(nil)
+	this.mu.Lock()
+	fn(func(e barEvent) { events = append(events, e) })
+	this.mu.Unlock()
+	if this.onClose == nil {
+		return
+	}
+	for _, e := range events {
+		this.onClose(e.code, e.Type, e.k)
+	}
+}
+
+func (this *BarBuilder) getCode(code string) *barCode {
+	c, ok := this.codes[code]
+	if !ok {
+		c = &barCode{bars: make(map[protocol.KlineType]*barState)}
+		this.codes[code] = c
+	}
+	return c
+}
+
+func (this *barCode) bar(Type protocol.KlineType) *barState {
+	st, ok := this.bars[Type]
+	if !ok {
+		st = &barState{}
+		this.bars[Type] = st
+	}
+	return st
+}
+
+// add 把一笔数据加到对应的k线,会先完成之前的k线
+func (this *BarBuilder) add(code string, Type protocol.KlineType, st *barState, t time.Time, price, last protocol.Price, volume int64, amount protocol.Price, emit func(barEvent)) {
+	t = t.In(protocol.Location)
+	p := barMinutes(Type)
+
+	//跨天,完成前一天的k线,重新开始
+	if day := barDate(t); !st.date.Equal(day) {
+		if !st.date.IsZero() {
+			this.advance(code, Type, st, 240, emit)
+		}
+		*st = barState{date: day, last: st.last}
+	}
+
+	idx := (tradingMinute(t) + p - 1) / p * p
+	if idx <= st.closed {
+		//延迟的数据,k线已经完成,算到下一根k线
+		if st.closed >= 240 {
+			return
+		}
+		idx = st.closed + p
+	}
+	this.advance(code, Type, st, idx-p, emit)
+
+	if st.k == nil {
+		if st.last == 0 {
+			st.last = last
+		}
+		st.idx = idx
+		st.k = &protocol.Kline{
+			Time:  barTime(st.date, idx),
+			Last:  st.last,
+			Open:  price,
+			High:  price,
+			Low:   price,
+			Close: price,
+		}
+	}
+	if price > st.k.High {
+		st.k.High = price
+	}
+	if price < st.k.Low {
+		st.k.Low = price
+	}
+	st.k.Close = price
+	if volume > 0 {
+		st.k.Volume += volume
+	}
+	if amount > 0 {
+		st.k.Amount += amount
+	}
+}
+
+// advance 完成结束序号<=upto的k线,中间没有数据的k线按上一根的收盘价生成平盘k线
+func (this *BarBuilder) advance(code string, Type protocol.KlineType, st *barState, upto int, emit func(barEvent)) {
+	if st.k != nil && st.idx <= upto {
+		emit(barEvent{code: code, Type: Type, k: st.k})
+		st.closed = st.idx
+		st.last = st.k.Close
+		st.k = nil
+	}
+	if st.k != nil || st.closed == 0 {
+		return
+	}
+	p := barMinutes(Type)
+	for next := st.closed + p; next <= upto && next <= 240; next += p {
+		emit(barEvent{code: code, Type: Type, k: &protocol.Kline{
+			Time:  barTime(st.date, next),
+			Last:  st.last,
+			Open:  st.last,
+			High:  st.last,
+			Low:   st.last,
+			Close: st.last,
+		}})
+		st.closed = next
+	}
+}
+
+// barMinutes k线类型对应的交易分钟数,不支持的类型返回0
+func barMinutes(Type protocol.KlineType) int {
+	switch Type {
+	case protocol.TypeKlineMinute:
+		return 1
+	case protocol.TypeKline5Minute:
+		return 5
+	case protocol.TypeKline15Minute:
+		return 15
+	case protocol.TypeKline30Minute:
+		return 30
+	case protocol.TypeKline60Minute:
+		return 60
+	case protocol.TypeKlineDay:
+		return 240
+	}
+	return 0
+}
+
+// tradingMinute 时间所属的交易分钟序号(1-240),9:30之前算第1分钟,11:30-13:00算第120分钟,15:00之后算第240分钟
+func tradingMinute(t time.Time) int {
+	m := t.Hour()*60 + t.Minute()
+	switch {
+	case m < 570:
+		return 1
+	case m < 690:
+		return m - 570 + 1
+	case m < 780:
+		return 120
+	case m < 900:
+		return m - 780 + 121
+	default:
+		return 240
+	}
+}
+
+// barTime 交易分钟序号对应的k线时间(结束时间)
+func barTime(date time.Time, idx int) time.Time {
+	m := 570 + idx
+	if idx > 120 {
+		m = 780 + idx - 120
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), m/60, m%60, 0, 0, protocol.Location)
+}
+
+func barDate(t time.Time) time.Time {
+	t = t.In(protocol.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, protocol.Location)
+}
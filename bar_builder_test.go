@@ -0,0 +1,67 @@
+package tdx
+
+import (
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+	"time"
+)
+
+func TestBarBuilder(t *testing.T) {
+	at := func(h, m, s int) time.Time {
+		return time.Date(2024, 10, 28, h, m, s, 0, protocol.Location)
+	}
+
+	closed := map[protocol.KlineType][]*protocol.Kline{}
+	b := NewBarBuilder(BarBuilderConfig{}, func(code string, Type protocol.KlineType, k *protocol.Kline) {
+		if code != "sz000001" {
+			t.Errorf("代码错误: %s", code)
+		}
+		closed[Type] = append(closed[Type], k)
+	})
+
+	b.AddTrade("000001",
+		&protocol.Trade{Time: at(9, 25, 0), Price: 10000, Volume: 10},
+		&protocol.Trade{Time: at(9, 30, 3), Price: 10010, Volume: 5},
+		&protocol.Trade{Time: at(9, 31, 30), Price: 9990, Volume: 7},
+		&protocol.Trade{Time: at(9, 34, 0), Price: 10020, Volume: 1},
+	)
+
+	//9:31和9:32的1分钟k线已经完成,9:33和9:34没有成交,在添加9:34:00(属于9:35)的成交时生成平盘k线
+	ls := closed[protocol.TypeKlineMinute]
+	if len(ls) != 4 {
+		t.Fatalf("预期4根1分钟k线,得到%d", len(ls))
+	}
+	if !ls[0].Time.Equal(at(9, 31, 0)) || ls[0].Open != 10000 || ls[0].Close != 10010 || ls[0].Volume != 15 {
+		t.Errorf("9:31的k线错误: %s", ls[0])
+	}
+	if ls[1].Last != 10010 || ls[1].Close != 9990 {
+		t.Errorf("9:32的k线错误: %s", ls[1])
+	}
+	if ls[2].Volume != 0 || ls[2].Close != 9990 || !ls[2].Time.Equal(at(9, 33, 0)) {
+		t.Errorf("9:33的平盘k线错误: %s", ls[2])
+	}
+	if k := b.Current("sz000001", protocol.TypeKline5Minute); k == nil || k.Volume != 23 || k.High != 10020 || k.Low != 9990 {
+		t.Errorf("进行中的5分钟k线错误: %v", k)
+	}
+
+	//午盘收盘,11:30的k线都完成
+	b.Tick(at(11, 30, 5))
+	if ls := closed[protocol.TypeKlineMinute]; len(ls) != 120 || !ls[119].Time.Equal(at(11, 30, 0)) {
+		t.Errorf("预期120根1分钟k线,得到%d", len(ls))
+	}
+	if ls := closed[protocol.TypeKline60Minute]; len(ls) != 2 || ls[0].Volume != 23 || !ls[1].Time.Equal(at(11, 30, 0)) {
+		t.Errorf("60分钟k线错误: %v", ls)
+	}
+	if len(closed[protocol.TypeKlineDay]) != 0 {
+		t.Errorf("日线不应该完成")
+	}
+
+	//收盘
+	b.Tick(at(15, 0, 5))
+	if ls := closed[protocol.TypeKlineDay]; len(ls) != 1 || ls[0].Volume != 23 || !ls[0].Time.Equal(at(15, 0, 0)) {
+		t.Errorf("日线错误: %v", ls)
+	}
+	if ls := closed[protocol.TypeKlineMinute]; len(ls) != 240 {
+		t.Errorf("预期240根1分钟k线,得到%d", len(ls))
+	}
+}
@@ -0,0 +1,108 @@
+package tdx
+
+import (
+	"sync"
+	"xorm.io/xorm"
+)
+
+// CheckpointModel 批量任务已经完成的(代码,数据表)
+type CheckpointModel struct {
+	ID     int64  `json:"id"`                    //主键
+	Name   string `json:"name" xorm:"index"`     //任务名称
+	Key    string `json:"key"`                   //这次执行的标识,例如目标交易日,不同标识的检查点不会恢复
+	Code   string `json:"code"`                  //代码
+	Table  string `json:"table"`                 //数据表
+	InDate int64  `json:"inDate" xorm:"created"` //创建时间
+}
+
+func (*CheckpointModel) TableName() string {
+	return "task_checkpoint"
+}
+
+// NewCheckpoint 批量任务的检查点,记录已经完成的(代码,数据表),程序中断后重新执行时跳过已经完成的
+// key是这次执行的标识,例如拉取的目标交易日(Workday.LastClose),key变化后之前的检查点会被删除,db为空只保存在内存中
+func NewCheckpoint(db *xorm.Engine, name, key string) (*Checkpoint, error) {
+	c := &Checkpoint{
+		db:   db,
+		name: name,
+		key:  key,
+		done: make(map[string]bool),
+	}
+	if db == nil {
+		return c, nil
+	}
+	if err := db.Sync2(new(CheckpointModel)); err != nil {
+		return nil, err
+	}
+	//删除其他标识的检查点
+	if _, err := db.Where("Name=? and `Key`<>?", name, key).Delete(new(CheckpointModel)); err != nil {
+		return nil, err
+	}
+	ls := []*CheckpointModel(nil)
+	if err := db.Where("Name=? and `Key`=?", name, key).Find(&ls); err != nil {
+		return nil, err
+	}
+	for _, v := range ls {
+		c.done[v.Code+"#"+v.Table] = true
+	}
+	return c, nil
+}
+
+type Checkpoint struct {
+	db   *xorm.Engine
+	name string
+	key  string
+	done map[string]bool
+	mu   sync.RWMutex
+}
+
+// Done 是否已经完成
+func (this *Checkpoint) Done(code, table string) bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.done[code+"#"+table]
+}
+
+// Len 已经完成的数量
+func (this *Checkpoint) Len() int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return len(this.done)
+}
+
+// Mark 标记为已经完成
+func (this *Checkpoint) Mark(code, table string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.done[code+"#"+table] {
+		return nil
+	}
+	if this.db != nil {
+		if _, err := this.db.Insert(&CheckpointModel{Name: this.name, Key: this.key, Code: code, Table: table}); err != nil {
+			return err
+		}
+	}
+	this.done[code+"#"+table] = true
+	return nil
+}
+
+// Clear 全部完成后清除检查点,下次执行从头开始
+func (this *Checkpoint) Clear() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.db != nil {
+		if _, err := this.db.Where("Name=?", this.name).Delete(new(CheckpointModel)); err != nil {
+			return err
+		}
+	}
+	this.done = make(map[string]bool)
+	return nil
+}
+
+// Checkpoint 使用任务执行器的数据库创建检查点,允许this为nil(只保存在内存中)
+func (this *TaskRunner) Checkpoint(name, key string) (*Checkpoint, error) {
+	if this == nil {
+		return NewCheckpoint(nil, name, key)
+	}
+	return NewCheckpoint(this.db, name, key)
+}
@@ -1,7 +1,7 @@
 package tdx
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"github.com/injoyai/base/maps"
 	"github.com/injoyai/base/maps/wait"
@@ -294,18 +294,13 @@ func (this *Client) GetETFAll() ([]string, error) {
 	return ls, nil
 }
 
-// GetQuote 获取盘口五档报价
+// GetQuote 获取盘口五档报价,会根据品种(股票,指数,ETF,可转债等)自动处理价格的小数位
 func (this *Client) GetQuote(codes ...string) (protocol.QuotesResp, error) {
+	instruments := make([]Instrument, len(codes))
 	for i := range codes {
-		//如果是股票代码,则加上前缀
-		codes[i] = protocol.AddPrefix(codes[i])
-		if !protocol.IsStock(codes[i]) {
-			if DefaultCodes == nil {
-				return nil, errors.New("DefaultCodes未初始化")
-			}
-			//不是股票代码的话，根据codes的信息加上前缀
-			codes[i] = DefaultCodes.AddExchange(codes[i])
-		}
+		//根据代码规则和DefaultCodes的信息加上前缀
+		instruments[i] = ClassifyInstrument(codes[i])
+		codes[i] = instruments[i].Code
 	}
 
 	f, err := protocol.MQuote.Frame(codes...)
@@ -318,32 +313,12 @@ func (this *Client) GetQuote(codes ...string) (protocol.QuotesResp, error) {
 	}
 	quotes := result.(protocol.QuotesResp)
 
-	{ //todo 临时处理下先,后续优化,感觉有问题
-		//判断长度和预期是否一致
-		if len(quotes) != len(codes) {
-			return nil, fmt.Errorf("预期%d个，实际%d个", len(codes), len(quotes))
-		}
-		for i, code := range codes {
-			if !protocol.IsStock(code) {
-				m := DefaultCodes.Get(code)
-				if m == nil {
-					return nil, fmt.Errorf("未查询到代码[%s]相关信息", code)
-				}
-				for ii, v := range quotes[i].SellLevel {
-					quotes[i].SellLevel[ii].Price = m.Price(v.Price)
-				}
-				for ii, v := range quotes[i].BuyLevel {
-					quotes[i].BuyLevel[ii].Price = m.Price(v.Price)
-				}
-				quotes[i].K = protocol.K{
-					Last:  m.Price(quotes[i].K.Last),
-					Open:  m.Price(quotes[i].K.Open),
-					High:  m.Price(quotes[i].K.High),
-					Low:   m.Price(quotes[i].K.Low),
-					Close: m.Price(quotes[i].K.Close),
-				}
-			}
-		}
+	//判断长度和预期是否一致
+	if len(quotes) != len(codes) {
+		return nil, fmt.Errorf("预期%d个，实际%d个", len(codes), len(quotes))
+	}
+	for i := range quotes {
+		instruments[i].Quote(quotes[i])
 	}
 
 	return quotes, nil
@@ -351,7 +326,7 @@ func (this *Client) GetQuote(codes ...string) (protocol.QuotesResp, error) {
 
 // GetMinute 获取分时数据,todo 解析好像不对,先用历史数据
 func (this *Client) GetMinute(code string) (*protocol.MinuteResp, error) {
-	return this.GetHistoryMinute(time.Now().Format("20060102"), code)
+	return this.GetHistoryMinute(protocol.Now().Format("20060102"), code)
 
 	f, err := protocol.MMinute.Frame(code)
 	if err != nil {
@@ -389,7 +364,7 @@ func (this *Client) GetMinuteTrade(code string, start, count uint16) (*protocol.
 		return nil, err
 	}
 	result, err := this.SendFrame(f, protocol.TradeCache{
-		Date: time.Now().Format("20060102"),
+		Date: protocol.Now().Format("20060102"),
 		Code: code,
 	})
 	if err != nil {
@@ -404,21 +379,15 @@ func (this *Client) GetTradeAll(code string) (*protocol.TradeResp, error) {
 
 // GetMinuteTradeAll 获取分时全部交易详情,todo 只做参考 因为交易实时在进行,然后又是分页读取的,所以会出现读取间隔内产生的交易会丢失
 func (this *Client) GetMinuteTradeAll(code string) (*protocol.TradeResp, error) {
-	resp := &protocol.TradeResp{}
-	size := uint16(1800)
-	for start := uint16(0); ; start += size {
-		r, err := this.GetMinuteTrade(code, start, size)
-		if err != nil {
-			return nil, err
-		}
-		resp.Count += r.Count
-		resp.List = append(r.List, resp.List...)
-
-		if r.Count < size {
-			break
-		}
+	pages := []protocol.Trades(nil)
+	err := this.RangeMinuteTrade(context.Background(), code, OrderDesc, func(ls protocol.Trades) bool {
+		pages = append(pages, ls)
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
-	return resp, nil
+	return joinTradePages(pages), nil
 }
 
 func (this *Client) GetHistoryTrade(date, code string, start, count uint16) (*protocol.TradeResp, error) {
@@ -452,26 +421,8 @@ func (this *Client) GetHistoryTradeFull(code string, w *Workday) (protocol.Trade
 // GetHistoryTradeBefore 获取上市至今的分时成交
 func (this *Client) GetHistoryTradeBefore(code string, w *Workday, before time.Time) (protocol.Trades, error) {
 	ls := protocol.Trades(nil)
-	resp, err := this.GetKlineMonthAll(code)
-	if err != nil {
-		return nil, err
-	}
-	if len(resp.List) == 0 {
-		return nil, nil
-	}
-	start := time.Date(resp.List[0].Time.Year(), resp.List[0].Time.Month(), 1, 0, 0, 0, 0, resp.List[0].Time.Location())
-	var res *protocol.TradeResp
-	w.Range(start, before, func(t time.Time) bool {
-		for i := 0; i < 3; i++ {
-			res, err = this.GetHistoryTradeDay(t.Format("20060102"), code)
-			if err == nil {
-				break
-			}
-		}
-		if err != nil {
-			return false
-		}
-		ls = append(ls, res.List...)
+	err := this.RangeHistoryTrade(context.Background(), code, w, before, OrderAsc, func(date time.Time, trades protocol.Trades) bool {
+		ls = append(ls, trades...)
 		return true
 	})
 	return ls, err
@@ -485,20 +436,26 @@ func (this *Client) GetHistoryTradeDay(date, code string) (*protocol.TradeResp,
 // GetHistoryMinuteTradeDay 获取历史某天分时全部交易,通过多次请求来拼接,只能获取昨天及之前的数据
 // 历史数据只能查到20000609
 func (this *Client) GetHistoryMinuteTradeDay(date, code string) (*protocol.TradeResp, error) {
+	pages := []protocol.Trades(nil)
+	err := this.RangeHistoryMinuteTrade(context.Background(), date, code, OrderDesc, func(ls protocol.Trades) bool {
+		pages = append(pages, ls)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return joinTradePages(pages), nil
+}
+
+// joinTradePages 拼接倒序分页获取的当天数据,结果按时间正序,并设置当天的序号
+func joinTradePages(pages []protocol.Trades) *protocol.TradeResp {
 	resp := &protocol.TradeResp{}
-	size := uint16(2000)
-	for start := uint16(0); ; start += size {
-		r, err := this.GetHistoryMinuteTrade(date, code, start, size)
-		if err != nil {
-			return nil, err
-		}
-		resp.Count += r.Count
-		resp.List = append(r.List, resp.List...)
-		if r.Count < size {
-			break
-		}
+	for i := len(pages) - 1; i >= 0; i-- {
+		resp.List = append(resp.List, pages[i]...)
 	}
-	return resp, nil
+	resp.List.Sequence(1)
+	resp.Count = uint16(len(resp.List))
+	return resp
 }
 
 /*
@@ -508,53 +465,17 @@ func (this *Client) GetHistoryMinuteTradeDay(date, code string) (*protocol.Trade
  */
 
 // GetIndex 获取指数,接口是和k线一样的,但是解析不知道怎么区分(解析方式不一致),所以加一个方法
-func (this *Client) GetIndex(Type uint8, code string, start, count uint16) (*protocol.KlineResp, error) {
-	code = protocol.AddPrefix(code)
-	f, err := protocol.MKline.Frame(Type, code, start, count)
-	if err != nil {
-		return nil, err
-	}
-	result, err := this.SendFrame(f, protocol.KlineCache{Type: Type, Kind: protocol.KindIndex})
-	if err != nil {
-		return nil, err
-	}
-	return result.(*protocol.KlineResp), nil
+func (this *Client) GetIndex(Type protocol.KlineType, code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline(Type, protocol.KindIndex, code, start, count)
 }
 
 // GetIndexUntil 获取指数k线数据，通过多次请求来拼接,直到满足func返回true
-func (this *Client) GetIndexUntil(Type uint8, code string, f func(k *protocol.Kline) bool) (*protocol.KlineResp, error) {
-	resp := &protocol.KlineResp{}
-	size := uint16(800)
-	var last *protocol.Kline
-	for start := uint16(0); ; start += size {
-		r, err := this.GetIndex(Type, code, start, size)
-		if err != nil {
-			return nil, err
-		}
-		if last != nil && len(r.List) > 0 {
-			last.Last = r.List[len(r.List)-1].Close
-		}
-		if len(r.List) > 0 {
-			last = r.List[0]
-		}
-		for i := len(r.List) - 1; i >= 0; i-- {
-			if f(r.List[i]) {
-				resp.Count += r.Count - uint16(i)
-				resp.List = append(r.List[i:], resp.List...)
-				return resp, nil
-			}
-		}
-		resp.Count += r.Count
-		resp.List = append(r.List, resp.List...)
-		if r.Count < size {
-			break
-		}
-	}
-	return resp, nil
+func (this *Client) GetIndexUntil(Type protocol.KlineType, code string, f func(k *protocol.Kline) bool) (*protocol.KlineResp, error) {
+	return this.getKlineUntil(Type, protocol.KindIndex, code, f)
 }
 
 // GetIndexAll 获取全部k线数据
-func (this *Client) GetIndexAll(Type uint8, code string) (*protocol.KlineResp, error) {
+func (this *Client) GetIndexAll(Type protocol.KlineType, code string) (*protocol.KlineResp, error) {
 	return this.GetIndexUntil(Type, code, func(k *protocol.Kline) bool { return false })
 }
 
@@ -592,53 +513,18 @@ func (this *Client) GetIndexYearAll(code string) (*protocol.KlineResp, error) {
  */
 
 // GetKline 获取k线数据,推荐收盘之后获取,否则会获取到当天的数据
-func (this *Client) GetKline(Type uint8, code string, start, count uint16) (*protocol.KlineResp, error) {
-	code = protocol.AddPrefix(code)
-	f, err := protocol.MKline.Frame(Type, code, start, count)
-	if err != nil {
-		return nil, err
-	}
-	result, err := this.SendFrame(f, protocol.KlineCache{Type: Type, Kind: protocol.KindStock})
-	if err != nil {
-		return nil, err
-	}
-	return result.(*protocol.KlineResp), nil
+// 会根据代码自动识别品种,指数(例sh000001)按指数解析,ETF,可转债等自动处理价格小数位
+func (this *Client) GetKline(Type protocol.KlineType, code string, start, count uint16) (*protocol.KlineResp, error) {
+	return this.getKline(Type, "", code, start, count)
 }
 
 // GetKlineUntil 获取k线数据，通过多次请求来拼接,直到满足func返回true
-func (this *Client) GetKlineUntil(Type uint8, code string, f func(k *protocol.Kline) bool) (*protocol.KlineResp, error) {
-	resp := &protocol.KlineResp{}
-	size := uint16(800)
-	var last *protocol.Kline
-	for start := uint16(0); ; start += size {
-		r, err := this.GetKline(Type, code, start, size)
-		if err != nil {
-			return nil, err
-		}
-		if last != nil && len(r.List) > 0 {
-			last.Last = r.List[len(r.List)-1].Close
-		}
-		if len(r.List) > 0 {
-			last = r.List[0]
-		}
-		for i := len(r.List) - 1; i >= 0; i-- {
-			if f(r.List[i]) {
-				resp.Count += r.Count - uint16(i)
-				resp.List = append(r.List[i:], resp.List...)
-				return resp, nil
-			}
-		}
-		resp.Count += r.Count
-		resp.List = append(r.List, resp.List...)
-		if r.Count < size {
-			break
-		}
-	}
-	return resp, nil
+func (this *Client) GetKlineUntil(Type protocol.KlineType, code string, f func(k *protocol.Kline) bool) (*protocol.KlineResp, error) {
+	return this.getKlineUntil(Type, "", code, f)
 }
 
 // GetKlineAll 获取全部k线数据
-func (this *Client) GetKlineAll(Type uint8, code string) (*protocol.KlineResp, error) {
+func (this *Client) GetKlineAll(Type protocol.KlineType, code string) (*protocol.KlineResp, error) {
 	return this.GetKlineUntil(Type, code, func(k *protocol.Kline) bool { return false })
 }
 
@@ -0,0 +1,215 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"github.com/injoyai/conv"
+	"github.com/injoyai/tdx/protocol"
+	"math"
+	"time"
+)
+
+// BarsRequest 通用的k线请求参数
+type BarsRequest struct {
+	Code  string             //代码,例sz000001,sh000001
+	Type  protocol.KlineType //k线类型,1分钟,日线等
+	Kind  string             //品种,protocol.KindStock或protocol.KindIndex,为空则根据代码自动识别
+	From  time.Time          //开始时间(包含),为零值则不限制
+	To    time.Time          //结束时间(包含),为零值则不限制
+	Limit int                //最多返回的数量,取最新的Limit条,<=0则不限制
+}
+
+// GetBars 通用的k线获取方法,会自动分页(单次最多800条),并按照时间范围和数量进行截取,结果按时间正序
+func (this *Client) GetBars(ctx context.Context, req BarsRequest) (*protocol.KlineResp, error) {
+	if req.Code == "" {
+		return nil, errors.New("代码不能为空")
+	}
+	if !req.Type.Valid() {
+		return nil, errors.New("未知的k线类型: " + req.Type.String())
+	}
+
+	//只取最新的少量数据,一次请求即可
+	if req.From.IsZero() && req.To.IsZero() && req.Limit > 0 && req.Limit <= 800 {
+		return this.getKline(req.Type, req.Kind, req.Code, 0, uint16(req.Limit))
+	}
+
+	//倒序收集,最后再反转
+	list := []*protocol.Kline(nil)
+	err := this.rangeKline(ctx, req.Type, req.Kind, req.Code, 0, func(ls []*protocol.Kline) bool {
+		for i := len(ls) - 1; i >= 0; i-- {
+			k := ls[i]
+			if !req.To.IsZero() && k.Time.After(req.To) {
+				continue
+			}
+			if !req.From.IsZero() && k.Time.Before(req.From) {
+				return false
+			}
+			list = append(list, k)
+			if req.Limit > 0 && len(list) >= req.Limit {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return &protocol.KlineResp{
+		Count: uint16(len(list)),
+		List:  list,
+	}, nil
+}
+
+// GetKlineRange 获取时间范围[from,to]内的k线,结果按时间正序
+// 服务器只支持按最新数据的偏移量查询,这里通过工作日估算to对应的偏移量,只拉取需要的分页,最后再精确截取
+// 日线及以上的k线时间是15:00,如果to是零点(只传了日期),则包含to当天的数据,时间推荐使用protocol.Location时区
+// w为nil时,从最新的数据开始往前拉取
+func (this *Client) GetKlineRange(Type protocol.KlineType, code string, from, to time.Time, w *Workday) (*protocol.KlineResp, error) {
+	ctx := context.Background()
+	if h, m, s := to.Clock(); !Type.IsMinute() && !to.IsZero() && h == 0 && m == 0 && s == 0 {
+		to = to.Add(time.Hour*24 - time.Second)
+	}
+	if w == nil {
+		return this.GetBars(ctx, BarsRequest{Code: code, Type: Type, From: from, To: to})
+	}
+	if !to.IsZero() && !from.IsZero() && to.Before(from) {
+		return nil, errors.New("结束时间不能早于开始时间")
+	}
+	return getKlineRange(ctx, Type, from, to, w, func(start, count uint16) (*protocol.KlineResp, error) {
+		return this.getKline(Type, "", code, start, count)
+	})
+}
+
+// klineFetch 获取偏移量[start,start+count)的k线,0是最新的数据,页内按时间正序
+type klineFetch func(start, count uint16) (*protocol.KlineResp, error)
+
+// getKlineRange 见GetKlineRange,to已经处理过只传日期的情况,w不为nil
+func getKlineRange(ctx context.Context, Type protocol.KlineType, from, to time.Time, w *Workday, fetch klineFetch) (*protocol.KlineResp, error) {
+	size := uint16(800)
+	start := w.estimateKlineOffset(Type, to)
+
+	//估算的偏移量可能偏大(例如停牌),需要往回找到包含to的分页,
+	//前一个偏移量(更新的一条)在to之后,说明to之前的数据都在start开始的分页里
+	for start > 0 {
+		r, err := fetch(start-1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(r.List) > 0 && (to.IsZero() || r.List[0].Time.After(to)) {
+			break
+		}
+		start = conv.Select(start > size, start-size, 0)
+	}
+
+	//倒序收集,最后再反转
+	list := []*protocol.Kline(nil)
+	err := rangeKlinePages(ctx, start, fetch, func(ls []*protocol.Kline) bool {
+		for i := len(ls) - 1; i >= 0; i-- {
+			k := ls[i]
+			if !to.IsZero() && k.Time.After(to) {
+				continue
+			}
+			if !from.IsZero() && k.Time.Before(from) {
+				return false
+			}
+			list = append(list, k)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return &protocol.KlineResp{
+		Count: uint16(len(list)),
+		List:  list,
+	}, nil
+}
+
+// getKline 获取k线数据,指数和个股的解析方式不一致,通过kind区分,kind为空则根据代码自动识别
+func (this *Client) getKline(Type protocol.KlineType, kind, code string, start, count uint16) (*protocol.KlineResp, error) {
+	i := ClassifyInstrument(code)
+	if kind == "" {
+		kind = i.Kind
+	}
+	f, err := protocol.MKline.Frame(Type, i.Code, start, count)
+	if err != nil {
+		return nil, err
+	}
+	result, err := this.SendFrame(f, protocol.KlineCache{Type: Type, Kind: kind})
+	if err != nil {
+		return nil, err
+	}
+	resp := result.(*protocol.KlineResp)
+	for _, k := range resp.List {
+		i.Kline(k)
+	}
+	return resp, nil
+}
+
+// getKlineUntil 获取k线数据，通过多次请求来拼接,直到满足func返回true
+func (this *Client) getKlineUntil(Type protocol.KlineType, kind, code string, f func(k *protocol.Kline) bool) (*protocol.KlineResp, error) {
+	pages := [][]*protocol.Kline(nil)
+	err := this.rangeKline(context.Background(), Type, kind, code, 0, func(ls []*protocol.Kline) bool {
+		for i := len(ls) - 1; i >= 0; i-- {
+			if f(ls[i]) {
+				pages = append(pages, ls[i:])
+				return false
+			}
+		}
+		pages = append(pages, ls)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	//页是从新到旧的,倒序拼接
+	resp := &protocol.KlineResp{}
+	for i := len(pages) - 1; i >= 0; i-- {
+		resp.List = append(resp.List, pages[i]...)
+	}
+	resp.Count = uint16(len(resp.List))
+	return resp, nil
+}
+
+// rangeKline 从偏移量start(0是最新的数据)开始往前分页获取k线,每页最多800条,页内按时间正序,f返回false则停止
+func (this *Client) rangeKline(ctx context.Context, Type protocol.KlineType, kind, code string, start uint16, f func(ls []*protocol.Kline) bool) error {
+	return rangeKlinePages(ctx, start, func(start, count uint16) (*protocol.KlineResp, error) {
+		return this.getKline(Type, kind, code, start, count)
+	}, f)
+}
+
+// rangeKlinePages 见rangeKline,通过fetch获取每页的数据
+func rangeKlinePages(ctx context.Context, start uint16, fetch klineFetch, f func(ls []*protocol.Kline) bool) error {
+	size := uint16(800)
+	var last *protocol.Kline
+	for ; ; start += size {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		r, err := fetch(start, size)
+		if err != nil {
+			return err
+		}
+		//上一页的第一条数据,昨收价是这一页的最后一条数据的收盘价
+		if last != nil && len(r.List) > 0 {
+			last.Last = r.List[len(r.List)-1].Close
+		}
+		if len(r.List) > 0 {
+			last = r.List[0]
+		}
+		if !f(r.List) || r.Count < size || start > math.MaxUint16-2*size {
+			return nil
+		}
+	}
+}
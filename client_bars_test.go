@@ -0,0 +1,118 @@
+package tdx
+
+import (
+	"context"
+	"github.com/injoyai/base/maps"
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+	"time"
+)
+
+// testKlines 模拟服务器的日k线,list按时间正序,calls是请求次数
+type testKlines struct {
+	list  []*protocol.Kline
+	calls int
+}
+
+func (this *testKlines) fetch(start, count uint16) (*protocol.KlineResp, error) {
+	this.calls++
+	end := len(this.list) - int(start)
+	if end < 0 {
+		end = 0
+	}
+	begin := end - int(count)
+	if begin < 0 {
+		begin = 0
+	}
+	ls := []*protocol.Kline(nil)
+	for _, v := range this.list[begin:end] {
+		k := *v
+		ls = append(ls, &k)
+	}
+	return &protocol.KlineResp{Count: uint16(len(ls)), List: ls}, nil
+}
+
+// testKlineWorkday 从2015年到昨天的周一到周五都是工作日,返回工作日和每个工作日15:00的日k线
+func testKlineWorkday() (*Workday, []*protocol.Kline) {
+	w := &Workday{cache: maps.NewBit()}
+	ls := []*protocol.Kline(nil)
+	today := IntegerDay(time.Now())
+	for t := time.Date(2015, 1, 1, 0, 0, 0, 0, protocol.Location); t.Before(today); t = t.AddDate(0, 0, 1) {
+		if wd := t.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			w.set(t)
+			ls = append(ls, &protocol.Kline{Time: t.Add(time.Hour * 15)})
+		}
+	}
+	return w, ls
+}
+
+func TestWorkday_estimateKlineOffset(t *testing.T) {
+	w, ls := testKlineWorkday()
+	to := time.Date(2023, 6, 30, 0, 0, 0, 0, protocol.Location)
+	after := 0
+	for _, v := range ls {
+		if v.Time.After(to.Add(time.Hour * 24)) {
+			after++
+		}
+	}
+	if n := w.estimateKlineOffset(protocol.TypeKlineDay, to); int(n) != after {
+		t.Errorf("日线预期偏移量%d,得到%d", after, n)
+	}
+	if n := w.estimateKlineOffset(protocol.TypeKline60Minute, time.Now().AddDate(0, 0, -14)); n < 4*5 || n > 4*10 {
+		t.Errorf("60分钟线预期偏移量在20-40之间,得到%d", n)
+	}
+	if n := w.estimateKlineOffset(protocol.TypeKlineWeek, to); n != 0 {
+		t.Errorf("周线预期从最新的开始,得到%d", n)
+	}
+	if n := w.estimateKlineOffset(protocol.TypeKlineDay, time.Time{}); n != 0 {
+		t.Errorf("零值预期从最新的开始,得到%d", n)
+	}
+}
+
+func Test_getKlineRange(t *testing.T) {
+	w, all := testKlineWorkday()
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, protocol.Location)
+	}
+	//2023年下半年停牌,估算的偏移量偏大,需要往回修正
+	suspend := []*protocol.Kline(nil)
+	for _, v := range all {
+		if v.Time.Before(day(2023, 7, 1)) || v.Time.After(day(2024, 1, 1)) {
+			suspend = append(suspend, v)
+		}
+	}
+
+	for _, v := range []struct {
+		name     string
+		list     []*protocol.Kline
+		from, to time.Time
+		maxCalls int //最多的请求次数
+	}{
+		{name: "半年", list: all, from: day(2023, 1, 1), to: day(2023, 6, 30).Add(time.Hour*24 - time.Second), maxCalls: 3},
+		{name: "跨多页", list: all, from: day(2016, 1, 1), to: day(2020, 12, 31).Add(time.Hour*24 - time.Second), maxCalls: 5},
+		{name: "停牌修正", list: suspend, from: day(2022, 1, 1), to: day(2023, 6, 30).Add(time.Hour*24 - time.Second), maxCalls: 5},
+		{name: "到最新", list: all, from: time.Now().AddDate(0, 0, -30), maxCalls: 1},
+	} {
+		src := &testKlines{list: v.list}
+		resp, err := getKlineRange(context.Background(), protocol.TypeKlineDay, v.from, v.to, w, src.fetch)
+		if err != nil {
+			t.Fatal(v.name, err)
+		}
+		want := []*protocol.Kline(nil)
+		for _, k := range v.list {
+			if !k.Time.Before(v.from) && (v.to.IsZero() || !k.Time.After(v.to)) {
+				want = append(want, k)
+			}
+		}
+		if len(resp.List) != len(want) || len(want) == 0 {
+			t.Errorf("%s: 预期%d条,得到%d条", v.name, len(want), len(resp.List))
+			continue
+		}
+		if !resp.List[0].Time.Equal(want[0].Time) || !resp.List[len(want)-1].Time.Equal(want[len(want)-1].Time) {
+			t.Errorf("%s: 范围错误 %s-%s", v.name, resp.List[0].Time, resp.List[len(want)-1].Time)
+		}
+		if src.calls > v.maxCalls {
+			t.Errorf("%s: 预期最多请求%d次,得到%d次", v.name, v.maxCalls, src.calls)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"github.com/injoyai/tdx/protocol"
+	"math"
+	"time"
+)
+
+// Order 分页遍历的顺序
+type Order uint8
+
+const (
+	OrderDesc Order = iota //倒序,从最新的数据开始往前,和服务器的分页方式一致,边拉取边回调
+	OrderAsc               //正序,从最早的数据开始往后,需要先探测数据总量(约十几次小请求),每页和上一页重叠匹配,盘中新增的数据不会遗漏
+)
+
+// RangeKline 分页遍历k线,每页最多800条,页内按时间正序,边拉取边回调,f返回false则停止
+// 适合数据量大(例如24000条1分钟k线)的情况,边拉取边入库,不需要把全部数据放在内存里
+// 倒序遍历时,每页第一条k线的昨收价(Last)在拉取到下一页时才会补上
+func (this *Client) RangeKline(ctx context.Context, Type protocol.KlineType, code string, order Order, f func(ls []*protocol.Kline) bool) error {
+	if order == OrderDesc {
+		return this.rangeKline(ctx, Type, "", code, 0, f)
+	}
+
+	var last *protocol.Kline
+	return rangeOffset(ctx, 800, OrderAsc, func(start, count uint16) ([]*protocol.Kline, error) {
+		r, err := this.getKline(Type, "", code, start, count)
+		if err != nil {
+			return nil, err
+		}
+		return r.List, nil
+	}, func(a, b *protocol.Kline) bool {
+		return a.Time.Equal(b.Time)
+	}, func(ls []*protocol.Kline) bool {
+		if last != nil {
+			ls[0].Last = last.Close
+		}
+		last = ls[len(ls)-1]
+		return f(ls)
+	})
+}
+
+// RangeMinuteTrade 分页遍历今天的分时成交,每页最多1800条,页内按时间正序,f返回false则停止
+func (this *Client) RangeMinuteTrade(ctx context.Context, code string, order Order, f func(ls protocol.Trades) bool) error {
+	return rangeOffset(ctx, 1800, order, func(start, count uint16) ([]*protocol.Trade, error) {
+		r, err := this.GetMinuteTrade(code, start, count)
+		if err != nil {
+			return nil, err
+		}
+		return r.List, nil
+	}, (*protocol.Trade).Equal, func(ls []*protocol.Trade) bool { return f(ls) })
+}
+
+// RangeHistoryMinuteTrade 分页遍历历史某天的分时成交,每页最多2000条,页内按时间正序,f返回false则停止
+func (this *Client) RangeHistoryMinuteTrade(ctx context.Context, date, code string, order Order, f func(ls protocol.Trades) bool) error {
+	return rangeOffset(ctx, 2000, order, func(start, count uint16) ([]*protocol.Trade, error) {
+		r, err := this.GetHistoryMinuteTrade(date, code, start, count)
+		if err != nil {
+			return nil, err
+		}
+		return r.List, nil
+	}, (*protocol.Trade).Equal, func(ls []*protocol.Trade) bool { return f(ls) })
+}
+
+// RangeHistoryTrade 按天遍历上市至before的分时成交,每天回调一次,f返回false则停止,单天失败会重试3次
+func (this *Client) RangeHistoryTrade(ctx context.Context, code string, w *Workday, before time.Time, order Order, f func(date time.Time, ls protocol.Trades) bool) error {
+	resp, err := this.GetKlineMonthAll(code)
+	if err != nil {
+		return err
+	}
+	if len(resp.List) == 0 {
+		return nil
+	}
+	first := resp.List[0].Time
+	start := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, first.Location())
+
+	days := []time.Time(nil)
+	w.Range(start, before, func(t time.Time) bool {
+		days = append(days, t)
+		return true
+	})
+
+	for i := range days {
+		t := days[i]
+		if order == OrderDesc {
+			t = days[len(days)-1-i]
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var res *protocol.TradeResp
+		for j := 0; j < 3; j++ {
+			res, err = this.GetHistoryTradeDay(t.Format("20060102"), code)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !f(t, res.List) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// ErrRangeShifted 正序分页时没有在新的一页里找到上一页的数据,分页期间新增的数据太多,可以收盘后再获取
+var ErrRangeShifted = errors.New("分页期间数据变化太多,没有找到和上一页重叠的数据")
+
+// rangeOffset 通用的按偏移量(0是最新的数据)分页遍历,fetch获取偏移量[start,start+count)的数据,按时间正序返回
+// 倒序时从偏移量0开始,直到数据不足一页
+// 正序时先探测数据总量,再从最早的分页开始往后,盘中新增的数据会让偏移量变大,
+// 所以每页都和已经回调的最后几条(equal判断相同)重叠匹配,从匹配位置之后继续,
+// 两页之间新增的数据超过一页的1/10时匹配不到,返回ErrRangeShifted
+func rangeOffset[T any](ctx context.Context, size uint16, order Order, fetch func(start, count uint16) ([]T, error), equal func(a, b T) bool, f func(ls []T) bool) error {
+
+	if order == OrderDesc {
+		for start := uint16(0); ; start += size {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			ls, err := fetch(start, size)
+			if err != nil {
+				return err
+			}
+			if len(ls) == 0 {
+				return nil
+			}
+			if !f(ls) || len(ls) < int(size) || int(start)+2*int(size) > math.MaxUint16 {
+				return nil
+			}
+		}
+	}
+
+	const anchor = 3        //用来匹配的已回调数据的数量
+	slack := int(size) / 10 //允许每页之间新增的数量
+	if slack < 1 {
+		slack = 1
+	}
+
+	//第一页,从最早的数据开始,窗口比探测的数量多slack条,返回的数量不足一页才说明包含了最早的数据
+	var tail []T //已经回调的最后几条
+	pos := 0     //已经回调的最后一条在上次请求时的偏移量
+	for retry := 0; ; retry++ {
+		total, err := probeOffset(ctx, size, fetch)
+		if err != nil {
+			return err
+		}
+		start := total + slack - int(size)
+		if start < 0 {
+			start = 0
+		}
+		ls, err := fetch(uint16(start), size)
+		if err != nil {
+			return err
+		}
+		if len(ls) == 0 {
+			return nil
+		}
+		if len(ls) >= int(size) {
+			//探测之后新增的数据太多,重新探测
+			if retry >= 3 {
+				return ErrRangeShifted
+			}
+			continue
+		}
+		if !f(ls) || start == 0 {
+			return nil
+		}
+		tail = lastN(ls, anchor)
+		pos = start
+		break
+	}
+
+	//后面的页,窗口包含上一页的最后几条和允许新增的数量
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		start := pos + len(tail) + slack - int(size)
+		if start < 0 {
+			start = 0
+		}
+		ls, err := fetch(uint16(start), size)
+		if err != nil {
+			return err
+		}
+		i := matchTail(ls, tail, equal)
+		if i < 0 {
+			//新增的数据超过了slack,上一页的数据已经在窗口之前了
+			return ErrRangeShifted
+		}
+		if add := ls[i+1:]; len(add) > 0 {
+			if !f(add) {
+				return nil
+			}
+			tail = lastN(append(append([]T(nil), tail...), add...), anchor)
+		}
+		if start == 0 {
+			return nil
+		}
+		pos = start
+	}
+}
+
+// matchTail 在ls中查找和tail连续相同的位置,返回tail最后一条在ls中的下标,优先最早的位置,没有找到返回-1
+func matchTail[T any](ls, tail []T, equal func(a, b T) bool) int {
+	for i := len(tail) - 1; i < len(ls); i++ {
+		match := true
+		for j := range tail {
+			if !equal(ls[i-len(tail)+1+j], tail[j]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+func lastN[T any](ls []T, n int) []T {
+	if len(ls) > n {
+		ls = ls[len(ls)-n:]
+	}
+	return append([]T(nil), ls...)
+}
+
+// probeOffset 探测数据总量,先倍增找到没有数据的偏移量,再二分查找,每次只请求1条数据
+func probeOffset[T any](ctx context.Context, size uint16, fetch func(start, count uint16) ([]T, error)) (int, error) {
+	has := func(offset int) (bool, error) {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		default:
+		}
+		ls, err := fetch(uint16(offset), 1)
+		return len(ls) > 0, err
+	}
+
+	//lo是有数据的偏移量,hi是没有数据的偏移量
+	lo, hi := -1, int(size)
+	for {
+		ok, err := has(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		lo = hi
+		if hi >= math.MaxUint16 {
+			return math.MaxUint16, nil
+		}
+		hi *= 2
+		if hi > math.MaxUint16 {
+			hi = math.MaxUint16
+		}
+	}
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		ok, err := has(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
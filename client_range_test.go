@@ -0,0 +1,114 @@
+package tdx
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_rangeOffset(t *testing.T) {
+	//模拟服务器数据,偏移量0是最新的数据
+	data := make([]int, 2345)
+	for i := range data {
+		data[i] = i
+	}
+	fetch := func(start, count uint16) ([]int, error) {
+		end := len(data) - int(start)
+		if end <= 0 {
+			return nil, nil
+		}
+		begin := end - int(count)
+		if begin < 0 {
+			begin = 0
+		}
+		return data[begin:end], nil
+	}
+
+	for _, order := range []Order{OrderAsc, OrderDesc} {
+		ls := []int(nil)
+		err := rangeOffset(context.Background(), 100, order, fetch, equalInt, func(page []int) bool {
+			ls = append(ls, page...)
+			return true
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(ls) != len(data) {
+			t.Errorf("数量错误,预期%d,得到%d", len(data), len(ls))
+			return
+		}
+		if order == OrderAsc {
+			for i, v := range ls {
+				if v != i {
+					t.Errorf("正序错误,位置%d得到%d", i, v)
+					return
+				}
+			}
+		}
+	}
+}
+
+func equalInt(a, b int) bool { return a == b }
+
+func Test_rangeOffset_Shift(t *testing.T) {
+	for _, v := range []struct {
+		name string
+		add  int //每次请求之后服务器新增的数量
+		err  bool
+	}{
+		{"没有新增", 0, false},
+		{"少量新增", 3, false},
+		{"新增接近重叠", 10, false},
+		{"新增超过重叠", 30, true},
+	} {
+		//模拟服务器数据,偏移量0是最新的数据,每次请求之后新增数据(盘中)
+		data := make([]int, 2345)
+		for i := range data {
+			data[i] = i
+		}
+		fetch := func(start, count uint16) ([]int, error) {
+			end := len(data) - int(start)
+			begin := end - int(count)
+			if begin < 0 {
+				begin = 0
+			}
+			ls := []int(nil)
+			if end > 0 {
+				ls = append(ls, data[begin:end]...)
+			}
+			//探测数量的请求不新增,避免一直探测不完
+			if count > 1 {
+				for i := 0; i < v.add; i++ {
+					data = append(data, len(data))
+				}
+			}
+			return ls, nil
+		}
+
+		ls := []int(nil)
+		err := rangeOffset(context.Background(), 100, OrderAsc, fetch, equalInt, func(page []int) bool {
+			ls = append(ls, page...)
+			return true
+		})
+		if v.err {
+			if err != ErrRangeShifted {
+				t.Errorf("%s: 预期ErrRangeShifted,得到%v", v.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+		//不能遗漏和重复,到最后一次请求时的最新数据为止
+		for i, x := range ls {
+			if x != i {
+				t.Errorf("%s: 位置%d得到%d", v.name, i, x)
+				break
+			}
+		}
+		if len(ls) < 2345 {
+			t.Errorf("%s: 数量错误,得到%d", v.name, len(ls))
+		}
+	}
+}
@@ -1,17 +1,18 @@
 package tdx
 
 import (
+	"context"
 	"errors"
 	"github.com/injoyai/conv"
 	"github.com/injoyai/ios/client"
 	"github.com/injoyai/logs"
 	"github.com/injoyai/tdx/protocol"
-	"github.com/robfig/cron/v3"
+	"io"
 	"math"
-	"os"
-	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-	"xorm.io/core"
 	"xorm.io/xorm"
 )
 
@@ -27,85 +28,48 @@ func DialCodes(filename string, op ...client.Option) (*Codes, error) {
 }
 
 func NewCodesMysql(c *Client, dsn string) (*Codes, error) {
-
-	//连接数据库
-	db, err := xorm.NewEngine("mysql", dsn)
+	store, err := NewCodeStoreMysql(dsn)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMapper(core.SameMapper{})
-
-	return NewCodes(c, db)
+	return NewCodesStore(c, store)
 }
 
 func NewCodesSqlite(c *Client, filenames ...string) (*Codes, error) {
-
 	//如果没有指定文件名,则使用默认
-	defaultFilename := filepath.Join(DefaultDatabaseDir, "codes.db")
-	filename := conv.Default(defaultFilename, filenames...)
-	filename = conv.Select(filename == "", defaultFilename, filename)
-
-	//如果文件夹不存在就创建
-	dir, _ := filepath.Split(filename)
-	_ = os.MkdirAll(dir, 0777)
-
-	//连接数据库
-	db, err := xorm.NewEngine("sqlite", filename)
+	store, err := NewCodeStoreSqlite(conv.Default("", filenames...))
 	if err != nil {
 		return nil, err
 	}
-	db.SetMapper(core.SameMapper{})
-	db.DB().SetMaxOpenConns(1)
-
-	return NewCodes(c, db)
+	return NewCodesStore(c, store)
 }
 
 func NewCodes(c *Client, db *xorm.Engine) (*Codes, error) {
-
-	if err := db.Sync2(new(CodeModel)); err != nil {
-		return nil, err
-	}
-	if err := db.Sync2(new(UpdateModel)); err != nil {
+	store, err := NewCodeStoreXorm(db)
+	if err != nil {
 		return nil, err
 	}
+	return NewCodesStore(c, store)
+}
 
-	update := new(UpdateModel)
-	{ //查询或者插入一条数据
-		has, err := db.Where("`Key`=?", "codes").Get(update)
-		if err != nil {
-			return nil, err
-		} else if !has {
-			update.Key = "codes"
-			if _, err := db.Insert(update); err != nil {
-				return nil, err
-			}
-		}
+// NewCodesStore 使用自定义的存储,例如NewCodeStoreMemory,NewCodeStoreJSON
+// 只加载数据,不会定时更新,定时更新见Refresher.AddCodes
+func NewCodesStore(c *Client, store CodeStore) (*Codes, error) {
+
+	updateUnix, err := store.UpdateTime()
+	if err != nil {
+		return nil, err
 	}
 
 	cc := &Codes{
 		Client: c,
-		db:     db,
-	}
-
-	{ //设置定时器,每天早上9点更新数据
-		task := cron.New(cron.WithSeconds())
-		task.AddFunc("10 0 9 * * *", func() {
-			for i := 0; i < 3; i++ {
-				err := cc.Update()
-				if err == nil {
-					return
-				}
-				logs.Err(err)
-				<-time.After(time.Minute * 5)
-			}
-		})
-		task.Start()
+		store:  store,
 	}
 
 	{ //判断是否更新过,更新过则不更新
-		now := time.Now()
-		node := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, time.Local)
-		updateTime := time.Unix(update.Time, 0)
+		now := protocol.Now()
+		node := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, protocol.Location)
+		updateTime := time.Unix(updateUnix, 0)
 		if now.Sub(node) > 0 {
 			//当前时间在9点之后,且更新时间在9点之前,需要更新
 			if updateTime.Sub(node) < 0 {
@@ -124,28 +88,31 @@ func NewCodes(c *Client, db *xorm.Engine) (*Codes, error) {
 }
 
 type Codes struct {
-	*Client                         //客户端
-	db        *xorm.Engine          //数据库实例
-	Map       map[string]*CodeModel //股票缓存
-	list      []*CodeModel          //列表方式缓存
-	exchanges map[string][]string   //交易所缓存
+	*Client                                 //客户端
+	store     CodeStore                     //存储,数据库,内存或者文件
+	snapshot  atomic.Pointer[codesSnapshot] //代码缓存,每次更新整体替换
+	updateMu  sync.Mutex                    //更新互斥,避免定时更新和手动更新同时进行
+	listeners []func(c *CodesChange)        //代码变化的回调
+	mu        sync.RWMutex                  //回调和搜索配置的锁
+
+	searchConfig SearchConfig //搜索的配置
 }
 
 // GetName 获取股票名称
 func (this *Codes) GetName(code string) string {
-	if v, ok := this.Map[code]; ok {
+	if v := this.Get(code); v != nil {
 		return v.Name
 	}
 	return "未知"
 }
 
-// GetStocks 获取股票代码,sh6xxx sz0xx sz30xx
+// GetStocks 获取股票代码,sh6xxx sz0xx sz30xx,不包含已退市的
 func (this *Codes) GetStocks(limits ...int) []string {
 	limit := conv.Default(-1, limits...)
 	ls := []string(nil)
-	for _, m := range this.list {
+	for _, m := range this.load().list {
 		code := m.FullCode()
-		if protocol.IsStock(code) {
+		if protocol.IsStock(code) && !m.Delisted {
 			ls = append(ls, code)
 		}
 		if limit > 0 && len(ls) >= limit {
@@ -155,13 +122,13 @@ func (this *Codes) GetStocks(limits ...int) []string {
 	return ls
 }
 
-// GetETFs 获取基金代码,sz159xxx,sh510xxx,sh511xxx
+// GetETFs 获取基金代码,sz159xxx,sh510xxx,sh511xxx,不包含已退市的
 func (this *Codes) GetETFs(limits ...int) []string {
 	limit := conv.Default(-1, limits...)
 	ls := []string(nil)
-	for _, m := range this.list {
+	for _, m := range this.load().list {
 		code := m.FullCode()
-		if protocol.IsETF(code) {
+		if protocol.IsETF(code) && !m.Delisted {
 			ls = append(ls, code)
 		}
 		if limit > 0 && len(ls) >= limit {
@@ -171,32 +138,132 @@ func (this *Codes) GetETFs(limits ...int) []string {
 	return ls
 }
 
+// CodeFilter 代码的筛选条件,为空的字段不限制
+type CodeFilter struct {
+	Kinds     []string                //品种,例protocol.KindStock,protocol.KindETF
+	Boards    []string                //板块,例protocol.BoardMain,protocol.BoardChiNext
+	Exchanges []string                //交易所,例sh,sz,bj
+	NoST      bool                    //排除ST和*ST
+	OnlyST    bool                    //只要ST和*ST
+	Delisted  bool                    //包含已退市的
+	Func      func(m *CodeModel) bool //自定义条件
+	Limit     int                     //最多返回的数量,<=0不限制
+}
+
+// Match 代码是否满足条件
+func (this CodeFilter) Match(m *CodeModel) bool {
+	in := func(ls []string, s string) bool {
+		if len(ls) == 0 {
+			return true
+		}
+		for _, v := range ls {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case m.Delisted && !this.Delisted,
+		this.NoST && m.ST,
+		this.OnlyST && !m.ST,
+		!in(this.Kinds, m.Kind),
+		!in(this.Boards, m.Board),
+		!in(this.Exchanges, m.Exchange),
+		this.Func != nil && !this.Func(m):
+		return false
+	}
+	return true
+}
+
+// List 按条件筛选代码,返回的是副本,例List(CodeFilter{Kinds: []string{protocol.KindStock}, Boards: []string{protocol.BoardChiNext}, NoST: true})
+func (this *Codes) List(filter CodeFilter) []*CodeModel {
+	ls := []*CodeModel(nil)
+	for _, m := range this.load().list {
+		if filter.Match(m) {
+			c := *m
+			ls = append(ls, &c)
+			if filter.Limit > 0 && len(ls) >= filter.Limit {
+				break
+			}
+		}
+	}
+	return ls
+}
+
+// Get 获取代码信息,返回的是副本,不存在返回nil
 func (this *Codes) Get(code string) *CodeModel {
-	return this.Map[code]
+	s := this.load()
+	m, ok := s.m[code]
+	if !ok {
+		m, ok = s.m[this.AddExchange(code)]
+	}
+	if !ok {
+		return nil
+	}
+	c := *m
+	return &c
 }
 
+// Count 代码数量,包含已退市的
+func (this *Codes) Count() int {
+	return len(this.load().list)
+}
+
+// AddExchange 添加交易所前缀,格式见ParseSymbol,识别不了的原样返回,允许this为nil
 func (this *Codes) AddExchange(code string) string {
-	return protocol.AddPrefix(code)
+	if s, err := this.ParseSymbol(code); err == nil {
+		return s.String()
+	}
+	return code
+}
+
+// ParseSymbol 解析证券代码,格式见protocol.ParseSymbol
+// 6位代码只在一个交易所存在时(例如可转债,债券)使用代码缓存的交易所,否则按代码规则识别,允许this为nil
+func (this *Codes) ParseSymbol(code string) (protocol.Symbol, error) {
+	code = strings.TrimSpace(code)
+	if this != nil && len(code) == 6 {
+		if exchanges := this.load().exchanges[code]; len(exchanges) == 1 {
+			code = exchanges[0] + code
+		}
+	}
+	return protocol.ParseSymbol(code)
+}
+
+// Close 等待执行中的更新结束,关闭存储(实现了io.Closer的,例如数据库)
+// 不会关闭客户端,客户端一般和Workday等共用,需要另外关闭
+func (this *Codes) Close() error {
+	this.updateMu.Lock()
+	defer this.updateMu.Unlock()
+	if c, ok := this.store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 // Update 更新数据,从服务器或者数据库
 func (this *Codes) Update(byDB ...bool) error {
-	codes, err := this.GetCodes(len(byDB) > 0 && byDB[0])
+	c, err := this.update(len(byDB) > 0 && byDB[0])
+	//释放更新锁之后再通知订阅者
+	this.notify(c)
+	return err
+}
+
+// update 加锁更新数据,返回代码的变化
+func (this *Codes) update(byDB bool) (*CodesChange, error) {
+	this.updateMu.Lock()
+	defer this.updateMu.Unlock()
+	codes, err := this.GetCodes(byDB)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	codeMap := make(map[string]*CodeModel)
-	exchanges := make(map[string][]string)
-	for _, code := range codes {
-		codeMap[code.Exchange+code.Code] = code
-		exchanges[code.Code] = append(exchanges[code.Code], code.Exchange)
+	history, err := this.getHistory()
+	if err != nil {
+		return nil, err
 	}
-	this.Map = codeMap
-	this.list = codes
-	this.exchanges = exchanges
+	c := this.swap(newCodesSnapshot(codes, history))
 	//更新时间
-	_, err = this.db.Where("`Key`=?", "codes").Update(&UpdateModel{Time: time.Now().Unix()})
-	return err
+	return c, this.store.SetUpdateTime(time.Now().Unix())
 }
 
 // GetCodes 更新股票并返回结果
@@ -207,42 +274,71 @@ func (this *Codes) GetCodes(byDatabase bool) ([]*CodeModel, error) {
 	}
 
 	//2. 查询数据库所有股票
-	list := []*CodeModel(nil)
-	if err := this.db.Find(&list); err != nil {
+	list, err := this.store.LoadCodes()
+	if err != nil {
 		return nil, err
 	}
 
 	//如果是从缓存读取,则返回结果
 	if byDatabase {
+		for _, v := range list {
+			v.classify()
+		}
 		return list, nil
 	}
 
+	//同一个代码在不同的交易所可能是不同的证券,例sh000001和sz000001,需要带上交易所
 	mCode := make(map[string]*CodeModel, len(list))
+	active := make(map[string]int) //每个交易所之前未退市的数量
 	for _, v := range list {
-		mCode[v.Code] = v
+		v.classify()
+		mCode[v.FullCode()] = v
+		if !v.Delisted {
+			active[v.Exchange]++
+		}
 	}
 
+	//数据库为空(第一次初始化)时,不知道代码第一次出现的日期
+	today := IntegerDay(time.Now()).Unix()
+	firstSeen := conv.Select(len(list) == 0, int64(0), today)
+
 	//3. 从服务器获取所有股票代码
 	insert := []*CodeModel(nil)
 	update := []*CodeModel(nil)
+	histories := []*CodeHistoryModel(nil)
+	seen := make(map[string]bool)
+	counts := make(map[string]int) //每个交易所服务器返回的数量
 	for _, exchange := range []protocol.Exchange{protocol.ExchangeSH, protocol.ExchangeSZ, protocol.ExchangeBJ} {
 		resp, err := this.Client.GetCodeAll(exchange)
 		if err != nil {
 			return nil, err
 		}
+		counts[exchange.String()] = len(resp.List)
 		for _, v := range resp.List {
-			if _, ok := mCode[v.Code]; ok {
-				if mCode[v.Code].Name != v.Name {
-					mCode[v.Code].Name = v.Name
-					update = append(update, &CodeModel{
-						Name:      v.Name,
-						Code:      v.Code,
-						Exchange:  exchange.String(),
-						Multiple:  v.Multiple,
-						Decimal:   v.Decimal,
-						LastPrice: v.LastPrice,
+			key := exchange.String() + v.Code
+			seen[key] = true
+			if m, ok := mCode[key]; ok {
+				if m.Name != v.Name {
+					histories = append(histories, &CodeHistoryModel{
+						Exchange: m.Exchange,
+						Code:     m.Code,
+						OldName:  m.Name,
+						Name:     v.Name,
+						Date:     today,
 					})
 				}
+				//昨收价格和最后出现的日期每天都会变,只更新内存,其他字段有变化时才保存,避免每天更新全部的代码
+				changed := m.Name != v.Name || m.Multiple != v.Multiple || m.Decimal != v.Decimal || m.Delisted
+				m.Name = v.Name
+				m.Multiple = v.Multiple
+				m.Decimal = v.Decimal
+				m.LastPrice = v.LastPrice
+				m.LastSeen = today
+				m.Delisted = false
+				if changed {
+					m.classify()
+					update = append(update, m)
+				}
 			} else {
 				code := &CodeModel{
 					Name:      v.Name,
@@ -251,55 +347,139 @@ func (this *Codes) GetCodes(byDatabase bool) ([]*CodeModel, error) {
 					Multiple:  v.Multiple,
 					Decimal:   v.Decimal,
 					LastPrice: v.LastPrice,
+					FirstSeen: firstSeen,
+					LastSeen:  today,
 				}
+				code.classify()
+				mCode[key] = code
 				insert = append(insert, code)
 				list = append(list, code)
 			}
 		}
 	}
 
-	switch this.db.Dialect().URI().DBType {
-	case "mysql":
-		// 1️⃣ 清空
-		if _, err := this.db.Exec("TRUNCATE TABLE codes"); err != nil {
-			return nil, err
+	//服务器已经没有的代码,标记为退市
+	update = append(update, delistCodes(list, seen, active, counts)...)
+
+	//4. 保存代码和名称变更记录
+	if err := this.store.SaveCodes(list, insert, update, histories); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// CodesDelistMaxRatio 单次更新最多标记退市的比例,交易所的代码数量比之前少了这个比例以上时,
+// 认为是服务器返回的数据不完整,不标记这个交易所的退市
+var CodesDelistMaxRatio = 0.05
+
+// delistCodes 把服务器已经没有的代码标记为退市,返回标记的代码
+// active是每个交易所之前未退市的数量,counts是每个交易所服务器返回的数量
+func delistCodes(list []*CodeModel, seen map[string]bool, active, counts map[string]int) []*CodeModel {
+	skip := make(map[string]bool)
+	for exchange, n := range active {
+		if float64(counts[exchange]) < float64(n)*(1-CodesDelistMaxRatio) {
+			logs.Warnf("交易所[%s]的代码数量从%d减少到%d,跳过退市标记\n", exchange, n, counts[exchange])
+			skip[exchange] = true
 		}
+	}
+	ls := []*CodeModel(nil)
+	for _, v := range list {
+		if !seen[v.FullCode()] && !v.Delisted && !skip[v.Exchange] {
+			v.Delisted = true
+			ls = append(ls, v)
+		}
+	}
+	return ls
+}
 
-		data := append(insert, update...)
-		// 2️⃣ 直接批量插入
-		batchSize := 3000 // 8000(2m16s) 5000(43s) 3000(11s) 1000(59s)
-		for i := 0; i < len(data); i += batchSize {
-			end := i + batchSize
-			if end > len(data) {
-				end = len(data)
-			}
+// getHistory 从存储加载名称变更记录
+func (this *Codes) getHistory() (map[string][]*CodeHistoryModel, error) {
+	list, err := this.store.LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string][]*CodeHistoryModel)
+	for _, v := range list {
+		m[v.Exchange+v.Code] = append(m[v.Exchange+v.Code], v)
+	}
+	return m, nil
+}
 
-			slice := conv.Array(data[i:end])
-			if _, err := this.db.Insert(slice); err != nil {
-				return nil, err
-			}
+// History 获取代码的名称变更记录,按日期正序,返回的是副本
+func (this *Codes) History(code string) []*CodeHistoryModel {
+	ls := this.load().history[this.AddExchange(code)]
+	result := make([]*CodeHistoryModel, len(ls))
+	for i, v := range ls {
+		c := *v
+		result[i] = &c
+	}
+	return result
+}
+
+// NameAt 获取代码在某天的名称,例如用于回测时判断当时是否是ST
+// 名称变更记录从开始记录时才有,更早的日期返回第一次记录的变更前的名称
+func (this *Codes) NameAt(code string, date time.Time) string {
+	code = this.AddExchange(code)
+	m := this.Get(code)
+	if m == nil {
+		return ""
+	}
+	name := m.Name
+	day := IntegerDay(date).Unix()
+	ls := this.load().history[code]
+	for i := len(ls) - 1; i >= 0 && ls[i].Date > day; i-- {
+		name = ls[i].OldName
+	}
+	return name
+}
+
+// FillListDate 用服务器日k线的第一天作为上市日期,补全股票的上市日期(ListDate)并保存,返回补全的数量
+// codes为空时处理全部没有上市日期的股票,每个代码需要十几次请求,适合初始化之后执行一次
+// 服务器的日k线从1990年开始才有,更早上市的股票得到的是k线开始的日期
+func (this *Codes) FillListDate(ctx context.Context, codes ...string) (int, error) {
+	if this.Client == nil {
+		return 0, errors.New("client is nil")
+	}
+	this.updateMu.Lock()
+	defer this.updateMu.Unlock()
+
+	s := this.load()
+	fill := make(map[string]bool)
+	for _, v := range codes {
+		fill[this.AddExchange(v)] = true
+	}
+
+	//快照创建之后不能修改,有变化的代码复制一份
+	list := make([]*CodeModel, len(s.list))
+	update := []*CodeModel(nil)
+	var err error
+	for i, v := range s.list {
+		list[i] = v
+		if err != nil || v.ListDate > 0 || v.Kind != protocol.KindStock || (len(fill) > 0 && !fill[v.FullCode()]) {
+			continue
 		}
-	case "sqlite3":
-		//4. 插入或者更新数据库
-		err := NewSessionFunc(this.db, func(session *xorm.Session) error {
-			for _, v := range insert {
-				if _, err := session.Insert(v); err != nil {
-					return err
-				}
-			}
-			for _, v := range update {
-				if _, err := session.Where("Exchange=? and Code=? ", v.Exchange, v.Code).Cols("Name,LastPrice").Update(v); err != nil {
-					return err
-				}
-			}
-			return nil
+		var first time.Time
+		err = this.Client.RangeKline(ctx, protocol.TypeKlineDay, v.FullCode(), OrderAsc, func(ls []*protocol.Kline) bool {
+			first = ls[0].Time
+			return false
 		})
-		if err != nil {
-			return nil, err
+		if err != nil || first.IsZero() {
+			continue
 		}
+		m := *v
+		m.ListDate = IntegerDay(first).Unix()
+		list[i] = &m
+		update = append(update, &m)
 	}
-
-	return list, nil
+	//出错时也保存已经补全的
+	if len(update) > 0 {
+		if err := this.store.SaveCodes(list, nil, update, nil); err != nil {
+			return 0, err
+		}
+		this.swap(newCodesSnapshot(list, s.history))
+	}
+	return len(update), err
 }
 
 type UpdateModel struct {
@@ -318,7 +498,15 @@ type CodeModel struct {
 	Exchange  string  `json:"exchange" xorm:"index"`   //交易所
 	Multiple  uint16  `json:"multiple"`                //倍数
 	Decimal   int8    `json:"decimal"`                 //小数位
-	LastPrice float64 `json:"lastPrice"`               //昨收价格
+	LastPrice float64 `json:"lastPrice"`               //昨收价格,其他字段有变化时才保存到数据库
+	Kind      string  `json:"kind" xorm:"index"`       //品种,protocol.KindStock,protocol.KindETF等
+	Board     string  `json:"board"`                   //股票的板块,protocol.BoardMain等,不是股票为空
+	ST        bool    `json:"st"`                      //是否是ST,包含*ST
+	StarST    bool    `json:"starST"`                  //是否是*ST
+	FirstSeen int64   `json:"firstSeen"`               //第一次出现的日期,0表示未知(初始化时已经存在),不一定是上市日期
+	ListDate  int64   `json:"listDate"`                //上市日期,0表示未知,服务器的代码列表没有上市日期,通过FillListDate设置
+	LastSeen  int64   `json:"lastSeen"`                //最后一次出现的日期,其他字段有变化时才保存到数据库
+	Delisted  bool    `json:"delisted"`                //是否已退市,服务器的代码列表里已经没有了
 	EditDate  int64   `json:"editDate" xorm:"updated"` //修改时间
 	InDate    int64   `json:"inDate" xorm:"created"`   //创建时间
 }
@@ -327,10 +515,34 @@ func (*CodeModel) TableName() string {
 	return "codes"
 }
 
+// CodeHistoryModel 代码的名称变更记录
+type CodeHistoryModel struct {
+	ID       int64  `json:"id"`                    //主键
+	Exchange string `json:"exchange" xorm:"index"` //交易所
+	Code     string `json:"code" xorm:"index"`     //代码
+	OldName  string `json:"oldName"`               //变更前的名称
+	Name     string `json:"name"`                  //变更后的名称
+	Date     int64  `json:"date"`                  //生效日期,发现变更的当天
+	InDate   int64  `json:"inDate" xorm:"created"` //创建时间
+}
+
+func (*CodeHistoryModel) TableName() string {
+	return "code_history"
+}
+
 func (this *CodeModel) FullCode() string {
 	return this.Exchange + this.Code
 }
 
+// classify 根据代码和名称识别品种,板块和ST
+func (this *CodeModel) classify() {
+	code := this.FullCode()
+	this.Kind = protocol.CodeKind(code)
+	this.Board = protocol.CodeBoard(code)
+	this.ST = this.Kind == protocol.KindStock && protocol.IsST(this.Name)
+	this.StarST = this.ST && strings.Contains(this.Name, "*")
+}
+
 func (this *CodeModel) Price(p protocol.Price) protocol.Price {
 	return protocol.Price(float64(p) * math.Pow10(int(2-this.Decimal)))
 	//return p * protocol.Price(math.Pow10(int(2-this.Decimal)))
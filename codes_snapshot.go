@@ -0,0 +1,136 @@
+package tdx
+
+// codesSnapshot 代码缓存的快照,创建之后不再修改,更新时整体替换,读取不需要加锁
+type codesSnapshot struct {
+	m         map[string]*CodeModel          //代码信息,key是带交易所前缀的代码
+	list      []*CodeModel                   //列表方式缓存
+	exchanges map[string][]string            //6位代码对应的交易所,不包含已退市的
+	history   map[string][]*CodeHistoryModel //名称变更记录,按日期正序
+	index     *SearchIndex                   //搜索索引
+}
+
+var emptyCodesSnapshot = newCodesSnapshot(nil, nil)
+
+func newCodesSnapshot(list []*CodeModel, history map[string][]*CodeHistoryModel) *codesSnapshot {
+	s := &codesSnapshot{
+		m:         make(map[string]*CodeModel, len(list)),
+		list:      list,
+		exchanges: make(map[string][]string),
+		history:   history,
+	}
+	for _, v := range list {
+		s.m[v.FullCode()] = v
+		if !v.Delisted {
+			s.exchanges[v.Code] = append(s.exchanges[v.Code], v.Exchange)
+		}
+	}
+	return s
+}
+
+// CodesChange 代码的变化
+type CodesChange struct {
+	Added   []*CodeModel        //新增的代码(包含重新上市的)
+	Removed []*CodeModel        //移除的代码(退市)
+	Renamed []*CodeHistoryModel //名称变化的代码
+}
+
+// Empty 是否没有变化
+func (this *CodesChange) Empty() bool {
+	return len(this.Added) == 0 && len(this.Removed) == 0 && len(this.Renamed) == 0
+}
+
+// diff 对比2个快照的变化
+func (this *codesSnapshot) diff(newer *codesSnapshot) *CodesChange {
+	c := &CodesChange{}
+	for _, v := range newer.list {
+		old, ok := this.m[v.FullCode()]
+		switch {
+		case v.Delisted:
+			if ok && !old.Delisted {
+				c.Removed = append(c.Removed, v)
+			}
+		case !ok || old.Delisted:
+			c.Added = append(c.Added, v)
+		case old.Name != v.Name:
+			c.Renamed = append(c.Renamed, &CodeHistoryModel{
+				Exchange: v.Exchange,
+				Code:     v.Code,
+				OldName:  old.Name,
+				Name:     v.Name,
+			})
+		}
+	}
+	for _, v := range this.list {
+		if _, ok := newer.m[v.FullCode()]; !ok && !v.Delisted {
+			c.Removed = append(c.Removed, v)
+		}
+	}
+	return c
+}
+
+// load 获取当前的快照,允许this为nil
+func (this *Codes) load() *codesSnapshot {
+	if this == nil {
+		return emptyCodesSnapshot
+	}
+	if s := this.snapshot.Load(); s != nil {
+		return s
+	}
+	return emptyCodesSnapshot
+}
+
+// swap 替换快照并重建搜索索引,返回和上个快照的变化,第一次加载或者没有变化返回nil
+// 不在这里通知订阅者,调用方需要在释放锁之后调用notify,避免回调里调用Update等方法死锁
+func (this *Codes) swap(s *codesSnapshot) *CodesChange {
+	this.mu.RLock()
+	s.index = NewSearchIndex(this.searchConfig, s.list)
+	this.mu.RUnlock()
+	old := this.snapshot.Swap(s)
+	if old == nil {
+		return nil
+	}
+	if c := old.diff(s); !c.Empty() {
+		return c
+	}
+	return nil
+}
+
+// notify 通知订阅者代码的变化,c为nil不通知
+func (this *Codes) notify(c *CodesChange) {
+	if c == nil {
+		return
+	}
+	this.mu.RLock()
+	listeners := this.listeners
+	this.mu.RUnlock()
+	for _, f := range listeners {
+		f(c)
+	}
+}
+
+// OnChange 订阅代码的变化(新增,退市,改名),在更新代码的协程中回调,回调时已经释放了更新锁,可以调用Update等方法
+func (this *Codes) OnChange(f func(c *CodesChange)) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.listeners = append(this.listeners, f)
+}
+
+// SetSearchConfig 设置搜索的配置,并重建当前的搜索索引
+func (this *Codes) SetSearchConfig(cfg SearchConfig) {
+	this.updateMu.Lock()
+	defer this.updateMu.Unlock()
+	this.mu.Lock()
+	this.searchConfig = cfg
+	this.mu.Unlock()
+	if old := this.snapshot.Load(); old != nil {
+		s := *old
+		s.index = NewSearchIndex(cfg, s.list)
+		this.snapshot.Store(&s)
+	}
+}
+
+// Search 搜索证券,支持代码,名称,拼音首字母(例payh)和模糊匹配,返回的是副本
+// kinds 限制品种,为空使用SearchConfig.Kinds,limit<=0不限制数量
+func (this *Codes) Search(keyword string, limit int, kinds ...string) []*CodeModel {
+	return this.load().index.Search(keyword, limit, kinds...)
+}
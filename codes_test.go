@@ -0,0 +1,196 @@
+package tdx
+
+import (
+	"fmt"
+	"github.com/injoyai/base/maps"
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+	"time"
+)
+
+func TestCodes_List(t *testing.T) {
+	list := []*CodeModel{
+		{Exchange: "sz", Code: "000001", Name: "平安银行"},
+		{Exchange: "sz", Code: "300750", Name: "宁德时代"},
+		{Exchange: "sh", Code: "600000", Name: "*ST浦发"},
+		{Exchange: "sh", Code: "000001", Name: "上证指数"},
+		{Exchange: "sz", Code: "159915", Name: "创业板ETF"},
+		{Exchange: "sz", Code: "000002", Name: "万科A", Delisted: true},
+	}
+	for _, v := range list {
+		v.classify()
+	}
+	c := &Codes{}
+	c.swap(newCodesSnapshot(list, nil))
+
+	if !list[2].ST || !list[2].StarST || list[0].ST {
+		t.Errorf("ST识别错误")
+	}
+	if list[1].Board != protocol.BoardChiNext || list[3].Board != "" {
+		t.Errorf("板块识别错误")
+	}
+
+	for _, v := range []struct {
+		filter CodeFilter
+		want   int
+	}{
+		{CodeFilter{}, 5},
+		{CodeFilter{Delisted: true}, 6},
+		{CodeFilter{Kinds: []string{protocol.KindStock}}, 3},
+		{CodeFilter{Kinds: []string{protocol.KindStock}, NoST: true}, 2},
+		{CodeFilter{OnlyST: true}, 1},
+		{CodeFilter{Boards: []string{protocol.BoardChiNext}}, 1},
+		{CodeFilter{Exchanges: []string{"sh"}}, 2},
+		{CodeFilter{Kinds: []string{protocol.KindStock}, Limit: 1}, 1},
+		{CodeFilter{Func: func(m *CodeModel) bool { return m.Kind == protocol.KindETF }}, 1},
+	} {
+		if ls := c.List(v.filter); len(ls) != v.want {
+			t.Errorf("%+v 预期%d个,得到%d个", v.filter, v.want, len(ls))
+		}
+	}
+
+	if ls := c.GetStocks(); len(ls) != 3 {
+		t.Errorf("GetStocks不应该包含已退市的: %v", ls)
+	}
+}
+
+func TestCodes_NameAt(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 10, d, 0, 0, 0, 0, protocol.Location)
+	}
+	m := &CodeModel{Exchange: "sh", Code: "600000", Name: "浦发银行"}
+	c := &Codes{}
+	c.swap(newCodesSnapshot([]*CodeModel{m}, map[string][]*CodeHistoryModel{"sh600000": {
+		{Exchange: "sh", Code: "600000", OldName: "浦发银行", Name: "ST浦发", Date: day(10).Unix()},
+		{Exchange: "sh", Code: "600000", OldName: "ST浦发", Name: "浦发银行", Date: day(20).Unix()},
+	}}))
+	for d, want := range map[int]string{1: "浦发银行", 10: "ST浦发", 15: "ST浦发", 20: "浦发银行", 25: "浦发银行"} {
+		if name := c.NameAt("600000", day(d).Add(time.Hour*10)); name != want {
+			t.Errorf("%d号预期%s,得到%s", d, want, name)
+		}
+	}
+	//返回的是副本,修改不影响缓存
+	c.History("600000")[0].Name = "修改"
+	if h := c.History("600000"); len(h) != 2 || h[0].Name != "ST浦发" {
+		t.Errorf("名称变更记录被修改了: %v", h[0].Name)
+	}
+	if p := c.PriceLimit("sh600000", 10000, day(15), nil); p.Percent != 5 {
+		t.Errorf("ST期间预期5%%的涨跌幅限制,得到%d", p.Percent)
+	}
+}
+
+func TestCodes_PriceLimit(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 10, d, 0, 0, 0, 0, protocol.Location)
+	}
+	w := &Workday{cache: maps.NewBit()}
+	for d := 1; d <= 31; d++ {
+		if wd := day(d).Weekday(); wd != time.Saturday && wd != time.Sunday {
+			w.set(day(d))
+		}
+	}
+	c := &Codes{}
+	c.swap(newCodesSnapshot([]*CodeModel{
+		//升级后新增到数据库的老代码,第一次出现的日期不是上市日期
+		{Exchange: "sz", Code: "000001", Name: "平安银行", FirstSeen: day(21).Unix()},
+		{Exchange: "sh", Code: "603001", Name: "新股", ListDate: day(21).Unix()},
+	}, nil))
+	for _, v := range []struct {
+		code string
+		date time.Time
+		want int64
+	}{
+		{"sz000001", day(21), 10},
+		{"sz000001", day(23), 10},
+		{"sh603001", day(21), 0},
+		{"sh603001", day(25), 0},
+		{"sh603001", day(28), 10},
+	} {
+		if p := c.PriceLimit(v.code, 10000, v.date, w); p.Percent != v.want {
+			t.Errorf("%s %s 预期%d,得到%d", v.code, v.date.Format("20060102"), v.want, p.Percent)
+		}
+	}
+}
+
+func Test_delistCodes(t *testing.T) {
+	list := []*CodeModel(nil)
+	for i := 0; i < 100; i++ {
+		list = append(list, &CodeModel{Exchange: "sh", Code: fmt.Sprintf("600%03d", i)})
+		list = append(list, &CodeModel{Exchange: "sz", Code: fmt.Sprintf("000%03d", i)})
+	}
+	seen := make(map[string]bool)
+	for _, v := range list {
+		seen[v.FullCode()] = true
+	}
+	//sh少了2个,正常退市
+	delete(seen, "sh600001")
+	delete(seen, "sh600002")
+	//sz少了50个,服务器返回的数据不完整
+	for i := 0; i < 50; i++ {
+		delete(seen, fmt.Sprintf("sz000%03d", i))
+	}
+	ls := delistCodes(list, seen, map[string]int{"sh": 100, "sz": 100}, map[string]int{"sh": 98, "sz": 50})
+	if len(ls) != 2 || ls[0].FullCode() != "sh600001" || ls[1].FullCode() != "sh600002" {
+		t.Errorf("退市标记错误: %v", ls)
+	}
+	for _, v := range list {
+		if v.Delisted != (v.FullCode() == "sh600001" || v.FullCode() == "sh600002") {
+			t.Errorf("%s 退市状态错误", v.FullCode())
+		}
+	}
+}
+
+func TestCodes_OnChange(t *testing.T) {
+	store := NewCodeStoreMemory()
+	store.Codes = []*CodeModel{
+		{Exchange: "sz", Code: "000001", Name: "平安银行"},
+		{Exchange: "sz", Code: "000002", Name: "万科A"},
+		{Exchange: "sh", Code: "600000", Name: "浦发银行"},
+	}
+	c := &Codes{Client: &Client{}, store: store}
+	if err := c.Update(true); err != nil {
+		t.Fatal(err)
+	}
+
+	var change *CodesChange
+	c.OnChange(func(cc *CodesChange) {
+		change = cc
+		//回调时已经释放了更新锁,可以再次更新
+		c.SetSearchConfig(SearchConfig{})
+		if err := c.Update(true); err != nil {
+			t.Error(err)
+		}
+	})
+	store.Codes = []*CodeModel{
+		{Exchange: "sz", Code: "000001", Name: "平安银行"},
+		{Exchange: "sz", Code: "000002", Name: "万科A", Delisted: true},
+		{Exchange: "sh", Code: "600000", Name: "ST浦发"},
+		{Exchange: "sh", Code: "600001", Name: "N新股"},
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Update(true) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("回调里调用Update死锁")
+	}
+
+	if change == nil {
+		t.Fatal("预期有变化")
+	}
+	if len(change.Added) != 1 || change.Added[0].Code != "600001" {
+		t.Errorf("新增错误: %v", change.Added)
+	}
+	if len(change.Removed) != 1 || change.Removed[0].Code != "000002" {
+		t.Errorf("退市错误: %v", change.Removed)
+	}
+	if len(change.Renamed) != 1 || change.Renamed[0].OldName != "浦发银行" || change.Renamed[0].Name != "ST浦发" {
+		t.Errorf("改名错误: %v", change.Renamed)
+	}
+	if c.Count() != 4 || c.GetName("sh600000") != "ST浦发" || c.Get("600001") == nil {
+		t.Errorf("快照没有替换")
+	}
+}
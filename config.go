@@ -0,0 +1,394 @@
+package tdx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/injoyai/ios/client"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultConfigEnvPrefix = "TDX" //环境变量的前缀,例如TDX_STORAGE_DSN
+	DefaultWebListen       = ":8080"
+
+	StorageSqlite = "sqlite" //每种数据一个sqlite文件,默认
+	StorageMysql  = "mysql"  //使用同一个mysql数据库
+	StorageMemory = "memory" //只保存在内存中,每次启动重新从服务器获取
+	StorageJSON   = "json"   //json文件
+
+	DialRange  = "range"  //按顺序连接,失败了连下一个,默认
+	DialRandom = "random" //随机连接
+	DialRound  = "round"  //轮询,每次连接下一个
+)
+
+// Config 配置文件,用于创建Manage和web服务,见config.example.yaml
+// 加载顺序: 默认值 < 配置文件(yaml,toml) < 环境变量(TDX_开头,例如TDX_CLIENT_HOSTS=a,b)
+type Config struct {
+	Client   ClientConfig   `yaml:"client" toml:"client"`
+	Storage  StorageConfig  `yaml:"storage" toml:"storage"`
+	Schedule ScheduleConfig `yaml:"schedule" toml:"schedule"`
+	Pull     PullConfig     `yaml:"pull" toml:"pull"`
+	Web      WebConfig      `yaml:"web" toml:"web"`
+}
+
+// ClientConfig 服务器连接
+type ClientConfig struct {
+	Hosts     []string `yaml:"hosts" toml:"hosts"`         //服务器地址,为空使用内置的Hosts
+	Dial      string   `yaml:"dial" toml:"dial"`           //连接方式,range(默认),random,round
+	Pool      int      `yaml:"pool" toml:"pool"`           //连接池的客户端数量,默认1
+	Timeout   Duration `yaml:"timeout" toml:"timeout"`     //请求的超时时间,默认5秒
+	RateLimit float64  `yaml:"rateLimit" toml:"rateLimit"` //连接池每秒最多请求次数,0不限制,只限制连接池的请求(例如定时拉取k线),web接口和代码的更新不限速
+}
+
+// StorageConfig 代码,工作日和任务记录的存储
+type StorageConfig struct {
+	Driver   string `yaml:"driver" toml:"driver"`     //存储方式,sqlite(默认),mysql,memory,json
+	Dir      string `yaml:"dir" toml:"dir"`           //sqlite和json的文件目录,默认DefaultDatabaseDir
+	DSN      string `yaml:"dsn" toml:"dsn"`           //mysql的连接地址,例如user:pwd@tcp(127.0.0.1:3306)/tdx
+	Holidays string `yaml:"holidays" toml:"holidays"` //本地的休市安排文件,覆盖内置的数据
+}
+
+// ScheduleConfig 定时更新
+type ScheduleConfig struct {
+	Codes         string   `yaml:"codes" toml:"codes"`                 //代码的更新时间,cron表达式(带秒),默认DefaultCodesSpec
+	Workday       string   `yaml:"workday" toml:"workday"`             //工作日的更新时间,默认DefaultWorkdaySpec
+	Jitter        Duration `yaml:"jitter" toml:"jitter"`               //随机延迟,避免多个实例同时请求
+	Retry         int      `yaml:"retry" toml:"retry"`                 //失败重试次数,默认2,小于0不重试
+	RetryInterval Duration `yaml:"retryInterval" toml:"retryInterval"` //重试间隔,默认5分钟
+}
+
+// PullConfig 定时拉取k线,数据类型见extend.AllKlineType
+type PullConfig struct {
+	Spec   string   `yaml:"spec" toml:"spec"`     //拉取时间,cron表达式(带秒),为空不拉取
+	Tables []string `yaml:"tables" toml:"tables"` //数据类型,例如day,week
+	Codes  []string `yaml:"codes" toml:"codes"`   //拉取的代码,为空拉取全部股票
+	Dir    string   `yaml:"dir" toml:"dir"`       //sqlite的目录,默认DefaultDatabaseDir/kline,mysql使用Storage.DSN
+	Limit  int      `yaml:"limit" toml:"limit"`   //协程数量,默认1
+	Retry  int      `yaml:"retry" toml:"retry"`   //失败重试次数,重试只拉取失败的代码
+}
+
+// Enabled 是否开启定时拉取
+func (this PullConfig) Enabled() bool {
+	return this.Spec != ""
+}
+
+// WebConfig web服务
+type WebConfig struct {
+	Listen   string `yaml:"listen" toml:"listen"`     //监听地址,默认:8080
+	Token    string `yaml:"token" toml:"token"`       //访问令牌,设置后接口需要携带Authorization: Bearer <token>或者?token=
+	Username string `yaml:"username" toml:"username"` //Basic认证的用户名,和Token任一通过即可
+	Password string `yaml:"password" toml:"password"` //Basic认证的密码
+}
+
+// NewConfig 默认配置
+func NewConfig() *Config {
+	c := &Config{}
+	c.setDefault()
+	return c
+}
+
+// LoadConfig 加载配置文件并校验,根据后缀识别格式(.yaml,.yml,.toml),filename为空只使用默认值和环境变量
+func LoadConfig(filename string) (*Config, error) {
+	c := &Config{}
+	if filename != "" {
+		bs, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Unmarshal(filepath.Ext(filename), bs); err != nil {
+			return nil, fmt.Errorf("解析配置文件[%s]失败: %w", filename, err)
+		}
+	}
+	if err := c.LoadEnv(DefaultConfigEnvPrefix); err != nil {
+		return nil, err
+	}
+	c.setDefault()
+	return c, c.Validate()
+}
+
+// Unmarshal 解析配置,ext是格式,例如.yaml,.toml
+func (this *Config) Unmarshal(ext string, bs []byte) error {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "yaml", "yml":
+		d := yaml.NewDecoder(bytes.NewReader(bs))
+		d.KnownFields(true)
+		if err := d.Decode(this); err != nil && err != io.EOF {
+			return err
+		}
+		return nil
+	case "toml":
+		return toml.NewDecoder(bytes.NewReader(bs)).DisallowUnknownFields().Decode(this)
+	default:
+		return fmt.Errorf("不支持的配置格式: %s", ext)
+	}
+}
+
+// LoadEnv 使用环境变量覆盖配置,名称是前缀加上字段路径,例如TDX_CLIENT_POOL,TDX_WEB_LISTEN
+// 列表用逗号分隔,例如TDX_PULL_TABLES=day,week
+func (this *Config) LoadEnv(prefix string) error {
+	return loadEnv(reflect.ValueOf(this).Elem(), strings.ToUpper(prefix))
+}
+
+func loadEnv(v reflect.Value, prefix string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := prefix + "_" + strings.ToUpper(strings.Split(field.Tag.Get("yaml"), ",")[0])
+		fv := v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := loadEnv(fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fv, s); err != nil {
+			return fmt.Errorf("环境变量[%s]错误: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setEnvValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(interface{ UnmarshalText([]byte) error }); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		ls := []string(nil)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				ls = append(ls, item)
+			}
+		}
+		v.Set(reflect.ValueOf(ls))
+	default:
+		return fmt.Errorf("不支持的类型: %s", v.Kind())
+	}
+	return nil
+}
+
+func (this *Config) setDefault() {
+	if this.Client.Dial == "" {
+		this.Client.Dial = DialRange
+	}
+	if this.Client.Pool <= 0 {
+		this.Client.Pool = 1
+	}
+	if this.Client.Timeout <= 0 {
+		this.Client.Timeout = Duration(time.Second * 5)
+	}
+	if this.Storage.Driver == "" {
+		this.Storage.Driver = StorageSqlite
+	}
+	if this.Storage.Dir == "" {
+		this.Storage.Dir = DefaultDatabaseDir
+	}
+	if this.Schedule.Codes == "" {
+		this.Schedule.Codes = DefaultCodesSpec
+	}
+	if this.Schedule.Workday == "" {
+		this.Schedule.Workday = DefaultWorkdaySpec
+	}
+	if this.Pull.Dir == "" {
+		this.Pull.Dir = filepath.Join(this.Storage.Dir, "kline")
+	}
+	if this.Pull.Limit <= 0 {
+		this.Pull.Limit = 1
+	}
+	if this.Web.Listen == "" {
+		this.Web.Listen = DefaultWebListen
+	}
+}
+
+// Validate 校验配置,返回全部的错误
+func (this *Config) Validate() error {
+	errs := []error(nil)
+	add := func(format string, v ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	switch this.Client.Dial {
+	case DialRange, DialRandom, DialRound:
+	default:
+		add("client.dial: 未知的连接方式[%s],可选range,random,round", this.Client.Dial)
+	}
+	for _, v := range this.Client.Hosts {
+		if _, _, err := net.SplitHostPort(hostPort(v)); err != nil {
+			add("client.hosts: 地址[%s]错误: %v", v, err)
+		}
+	}
+	if this.Client.RateLimit < 0 {
+		add("client.rateLimit: 不能小于0")
+	}
+
+	switch this.Storage.Driver {
+	case StorageSqlite, StorageMemory, StorageJSON:
+	case StorageMysql:
+		if this.Storage.DSN == "" {
+			add("storage.dsn: mysql需要配置连接地址")
+		}
+	default:
+		add("storage.driver: 未知的存储方式[%s],可选sqlite,mysql,memory,json", this.Storage.Driver)
+	}
+	if this.Storage.Holidays != "" {
+		if _, err := os.Stat(this.Storage.Holidays); err != nil {
+			add("storage.holidays: %v", err)
+		}
+	}
+
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	for _, v := range [][2]string{
+		{"schedule.codes", this.Schedule.Codes},
+		{"schedule.workday", this.Schedule.Workday},
+		{"pull.spec", this.Pull.Spec},
+	} {
+		name, spec := v[0], v[1]
+		if spec == "" {
+			continue
+		}
+		if _, err := parser.Parse(spec); err != nil {
+			add("%s: cron表达式[%s]错误: %v", name, spec, err)
+		}
+	}
+
+	if this.Pull.Enabled() && len(this.Pull.Tables) == 0 {
+		add("pull.tables: 开启了定时拉取,需要配置数据类型")
+	}
+
+	if _, _, err := net.SplitHostPort(this.Web.Listen); err != nil {
+		add("web.listen: 地址[%s]错误: %v", this.Web.Listen, err)
+	}
+	if (this.Web.Username == "") != (this.Web.Password == "") {
+		add("web.username,web.password: 需要同时配置")
+	}
+
+	return errors.Join(errs...)
+}
+
+// hostPort 补全默认端口
+func hostPort(host string) string {
+	if !strings.Contains(host, ":") {
+		return host + ":7709"
+	}
+	return host
+}
+
+// DialFunc 按配置连接服务器,设置超时时间
+func (this ClientConfig) DialFunc() func(op ...client.Option) (*Client, error) {
+	return func(op ...client.Option) (c *Client, err error) {
+		switch this.Dial {
+		case DialRandom:
+			c, err = DialHostsRandom(this.Hosts, op...)
+		case DialRound:
+			c, err = DialHosts(this.Hosts, op...)
+		default:
+			c, err = DialHostsRange(this.Hosts, op...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if this.Timeout > 0 {
+			c.Wait.SetTimeout(time.Duration(this.Timeout))
+		}
+		return c, nil
+	}
+}
+
+// ManageConfig 转换成Manage的配置,mysql的连接地址放在CodesFilename和WorkdayFileName
+func (this *Config) ManageConfig() (*ManageConfig, error) {
+	cfg := &ManageConfig{
+		Number:           this.Client.Pool,
+		Dial:             this.Client.DialFunc(),
+		Timeout:          time.Duration(this.Client.Timeout),
+		RateLimit:        this.Client.RateLimit,
+		CodesSpec:        this.Schedule.Codes,
+		WorkdaySpec:      this.Schedule.Workday,
+		HolidaysFilename: this.Storage.Holidays,
+		Refresh: RefresherConfig{
+			Jitter:        time.Duration(this.Schedule.Jitter),
+			Retry:         this.Schedule.Retry,
+			RetryInterval: time.Duration(this.Schedule.RetryInterval),
+		},
+	}
+	dir := this.Storage.Dir
+	switch this.Storage.Driver {
+	case StorageMysql:
+		cfg.CodesFilename = this.Storage.DSN
+		cfg.WorkdayFileName = this.Storage.DSN
+	case StorageMemory:
+		cfg.CodeStore = NewCodeStoreMemory()
+		cfg.CalendarStore = NewCalendarStoreMemory()
+	case StorageJSON:
+		codeStore, err := NewCodeStoreJSON(filepath.Join(dir, "codes.json"))
+		if err != nil {
+			return nil, err
+		}
+		calendarStore, err := NewCalendarStoreJSON(filepath.Join(dir, "workday.json"))
+		if err != nil {
+			return nil, err
+		}
+		cfg.CodeStore, cfg.CalendarStore = codeStore, calendarStore
+	}
+	if this.Storage.Driver != StorageMysql {
+		cfg.CodesFilename = filepath.Join(dir, "codes.db")
+		cfg.WorkdayFileName = filepath.Join(dir, "workday.db")
+		cfg.TaskFilename = filepath.Join(dir, "task.db")
+	}
+	return cfg, nil
+}
+
+// NewManageFromConfig 按配置文件创建Manage,定时拉取k线见extend.RegisterPullKline
+func NewManageFromConfig(c *Config, op ...client.Option) (*Manage, error) {
+	cfg, err := c.ManageConfig()
+	if err != nil {
+		return nil, err
+	}
+	if c.Storage.Driver == StorageMysql {
+		return NewManageMysql(cfg, op...)
+	}
+	return NewManage(cfg, op...)
+}
+
+// Duration 时间间隔,配置文件中使用字符串,例如"5s","1m30s"
+type Duration time.Duration
+
+func (this Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(this).String()), nil
+}
+
+func (this *Duration) UnmarshalText(bs []byte) error {
+	d, err := time.ParseDuration(string(bs))
+	if err != nil {
+		return err
+	}
+	*this = Duration(d)
+	return nil
+}
@@ -0,0 +1,83 @@
+package tdx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	c, err := LoadConfig("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Client.Pool != 2 || c.Client.Timeout != Duration(time.Second*5) || c.Schedule.Jitter != Duration(time.Minute) ||
+		c.Pull.Dir != filepath.Join(DefaultDatabaseDir, "kline") || c.Web.Listen != ":8080" {
+		t.Errorf("yaml配置错误: %+v", c)
+	}
+
+	//toml,环境变量覆盖配置文件
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(filename, []byte(`
+[client]
+hosts = ["127.0.0.1"]
+timeout = "3s"
+[storage]
+driver = "mysql"
+dsn = "root:root@tcp(127.0.0.1:3306)/tdx"
+[pull]
+spec = "0 10 15 * * *"
+tables = ["day"]
+`), 0666)
+	t.Setenv("TDX_PULL_TABLES", "day, week")
+	t.Setenv("TDX_CLIENT_RATELIMIT", "10")
+	t.Setenv("TDX_SCHEDULE_RETRYINTERVAL", "30s")
+	c, err = LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Client.Timeout != Duration(time.Second*3) || c.Client.RateLimit != 10 || len(c.Pull.Tables) != 2 ||
+		c.Pull.Tables[1] != "week" || c.Schedule.RetryInterval != Duration(time.Second*30) || !c.Pull.Enabled() {
+		t.Errorf("toml配置错误: %+v", c)
+	}
+	cfg, err := c.ManageConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CodesFilename != c.Storage.DSN || cfg.RateLimit != 10 || cfg.Timeout != time.Second*3 || cfg.Refresh.RetryInterval != time.Second*30 {
+		t.Errorf("Manage配置错误: %+v", cfg)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := NewConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("默认配置应该正确: %v", err)
+	}
+
+	c.Client.Dial = "fast"
+	c.Storage.Driver = StorageMysql
+	c.Schedule.Codes = "0 9 * * *"
+	c.Pull.Spec = "0 0 15 * * *"
+	c.Web.Listen = "8080"
+	c.Web.Username = "admin"
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("应该返回错误")
+	}
+	for _, v := range []string{"client.dial", "storage.dsn", "schedule.codes", "pull.tables", "web.listen", "web.password"} {
+		if !strings.Contains(err.Error(), v) {
+			t.Errorf("缺少[%s]的错误: %v", v, err)
+		}
+	}
+
+	//未知的字段
+	if err := c.Unmarshal(".yaml", []byte("web:\n  port: 8080\n")); err == nil {
+		t.Errorf("未知的字段应该返回错误")
+	}
+	if err := c.Unmarshal(".json", nil); err == nil {
+		t.Errorf("不支持的格式应该返回错误")
+	}
+}
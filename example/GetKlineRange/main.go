@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/example/common"
+	"github.com/injoyai/tdx/protocol"
+	"time"
+)
+
+func main() {
+	common.Test(func(c *tdx.Client) {
+		w, err := tdx.NewWorkdaySqlite(c)
+		logs.PanicErr(err)
+		from := time.Date(2015, 1, 1, 0, 0, 0, 0, time.Local)
+		to := time.Date(2018, 12, 31, 0, 0, 0, 0, time.Local)
+		resp, err := c.GetKlineRange(protocol.TypeKlineDay, "sz000001", from, to, w)
+		logs.PanicErr(err)
+		for _, v := range resp.List {
+			logs.Debug(v)
+		}
+		logs.Debug(resp.Count)
+	})
+}
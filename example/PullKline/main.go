@@ -5,22 +5,37 @@ import (
 	"github.com/injoyai/logs"
 	"github.com/injoyai/tdx"
 	"github.com/injoyai/tdx/extend"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 )
 
 func main() {
 
+	//收到退出信号时取消拉取,下次执行从检查点继续
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
 	m, err := tdx.NewManage(nil)
 	logs.PanicErr(err)
+	logs.PanicErr(m.Start(ctx))
 
-	err = extend.NewPullKline(extend.PullKlineConfig{
+	pull := extend.NewPullKline(extend.PullKlineConfig{
 		Codes:   []string{"sz000001"},
 		Tables:  []string{extend.Year},
 		Dir:     filepath.Join(tdx.DefaultDatabaseDir, "kline"),
 		Limit:   1,
 		StartAt: time.Time{},
-	}).Run(context.Background(), m)
+	})
+	logs.PanicErr(m.Tasks.Register(pull, tdx.TaskOption{}))
+	err = m.Tasks.Run(ctx, pull.Name())
+
+	//等待任务结束,关闭数据库
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), tdx.DefaultCloseTimeout)
+	defer closeCancel()
+	logs.PrintErr(m.Close(closeCtx))
 	logs.PanicErr(err)
 
 }
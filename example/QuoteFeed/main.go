@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+)
+
+func main() {
+	m, err := tdx.NewManage(&tdx.ManageConfig{Number: 4})
+	logs.PanicErr(err)
+
+	f := tdx.NewQuoteFeed(m.Pool, tdx.QuoteFeedConfig{})
+	f.Subscribe("sz000001", "sh600000", "sz159558")
+	go func() { logs.Err(f.Run(context.Background())) }()
+
+	for e := range f.Events() {
+		if e.Change.Has(tdx.QuoteChangeVolume) {
+			logs.Debug(e.Code, e.Change, e.Quote.K.Close, e.Volume())
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
+)
+
+func main() {
+	m, err := tdx.NewManage(&tdx.ManageConfig{Number: 4})
+	logs.PanicErr(err)
+
+	t := tdx.NewTradeTracker(m.Pool, tdx.TradeTrackerConfig{}, func(code string, ls protocol.Trades) {
+		for _, v := range ls {
+			logs.Debug(code, v.Seq, v)
+		}
+	})
+	t.Add("sz000001", "sh600000")
+	logs.PanicErr(t.Run(context.Background()))
+}
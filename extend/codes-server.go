@@ -15,6 +15,12 @@ func ListenCodesHTTP(port int, filename ...string) error {
 	if err != nil {
 		return nil
 	}
+	//每天定时更新代码
+	r := tdx.NewRefresher(tdx.RefresherConfig{})
+	defer r.Close()
+	if err := r.AddCodes(code, ""); err != nil {
+		return err
+	}
 	return http.ListenAndServe(fmt.Sprintf(":%d", port), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.RequestURI {
 		case "/stocks":
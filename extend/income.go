@@ -7,8 +7,8 @@ import (
 )
 
 func DoIncomes(ks Klines, startAt time.Time, days ...int) Incomes {
-	year, month, day := startAt.Date()
-	start := time.Date(year, month, day, 15, 0, 0, 0, startAt.Location()).Unix()
+	year, month, day := startAt.In(protocol.Location).Date()
+	start := time.Date(year, month, day, 15, 0, 0, 0, protocol.Location).Unix()
 	for i, v := range ks {
 		if v.Date >= start {
 			ks = ks[i:]
@@ -23,7 +23,7 @@ func DoIncomes(ks Klines, startAt time.Time, days ...int) Incomes {
 			x := ks[v]
 			ls = append(ls, &Income{
 				Offset: v,
-				Time:   time.Unix(x.Date, 0),
+				Time:   time.Unix(x.Date, 0).In(protocol.Location),
 				Source: protocol.K{
 					Open:  ks[0].Open,
 					High:  ks[0].High,
@@ -7,6 +7,7 @@ import (
 	"github.com/injoyai/logs"
 	"github.com/injoyai/tdx"
 	"github.com/injoyai/tdx/protocol"
+	"sync/atomic"
 	"xorm.io/core"
 	"xorm.io/xorm"
 )
@@ -33,13 +34,22 @@ func NewPullKlineMysql(cfg PullKlineConfig) (*PullKlineMysql, error) {
 }
 
 type PullKlineMysql struct {
-	tables []*KlineTable
-	Config PullKlineConfig
-	DB     *xorm.Engine
+	tables   []*KlineTable
+	Config   PullKlineConfig
+	DB       *xorm.Engine
+	progress atomic.Pointer[tdx.Progress]
 }
 
+var _ tdx.Task = (*PullKlineMysql)(nil)
+
+// Name 任务名称,也是检查点的名称,需要和PullKline(sqlite)区分,否则两个任务会共用检查点,也不能同时注册
 func (this *PullKlineMysql) Name() string {
-	return "拉取k线数据"
+	return "拉取k线数据(mysql)"
+}
+
+// Progress 当前或最后一次执行的进度,没有执行过返回nil
+func (this *PullKlineMysql) Progress() *tdx.Progress {
+	return this.progress.Load()
 }
 
 func (this *PullKlineMysql) Run(ctx context.Context, m *tdx.Manage) error {
@@ -51,10 +61,17 @@ func (this *PullKlineMysql) Run(ctx context.Context, m *tdx.Manage) error {
 		codes = m.Codes.GetStocks()
 	}
 
+	progress, checkpoint, err := newPullProgress(ctx, m, this.Name(), this.tables, codes)
+	if err != nil {
+		return err
+	}
+	this.progress.Store(progress)
+
 	for _, v := range codes {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			limit.Wait()
+			return finishPull(ctx, this.Name(), progress, checkpoint)
 		default:
 		}
 
@@ -67,54 +84,62 @@ func (this *PullKlineMysql) Run(ctx context.Context, m *tdx.Manage) error {
 					continue
 				}
 
+				//已经完成的,跳过
+				if checkpoint.Done(code, table.TableName()) {
+					progress.Skip(table.TableName(), code)
+					continue
+				}
+
 				select {
 				case <-ctx.Done():
 					return
 				default:
 				}
 
-				var err error
-
-				//2. 获取最后一条数据
-				last := new(Kline)
-				if _, err = this.DB.Table(table).Where("Code=?", code).Desc("Date").Get(last); err != nil {
-					logs.Err(err)
-					return
-				}
-
-				//3. 从服务器获取数据
-				insert := Klines{}
-				err = m.Do(func(c *tdx.Client) error {
-					insert, err = this.pull(code, last.Date, table.Handler(c))
-					return err
-				})
-				if err != nil {
-					logs.Err(err)
-					return
+				err := this.pullTable(m, code, table)
+				progress.Done(table.TableName(), code, err)
+				if err == nil {
+					logs.PrintErr(checkpoint.Mark(code, table.TableName()))
 				}
-
-				//4. 插入数据库
-				err = tdx.NewSessionFunc(this.DB, func(session *xorm.Session) error {
-					for i, v := range insert {
-						if i == 0 {
-							if _, err := session.Table(table).Where("Code=? and Date >= ?", code, v.Date).Delete(); err != nil {
-								return err
-							}
-						}
-						if _, err := session.Table(table).Insert(v); err != nil {
-							return err
-						}
-					}
-					return nil
-				})
-				logs.PrintErr(err)
-
 			}
 
 		}(v)
 	}
 	limit.Wait()
-	return nil
+	return finishPull(ctx, this.Name(), progress, checkpoint)
+}
+
+func (this *PullKlineMysql) pullTable(m *tdx.Manage, code string, table *KlineTable) error {
+	//2. 获取最后一条数据
+	last := new(Kline)
+	if _, err := this.DB.Table(table).Where("Code=?", code).Desc("Date").Get(last); err != nil {
+		return err
+	}
+
+	//3. 从服务器获取数据
+	insert := Klines{}
+	err := m.Do(func(c *tdx.Client) (err error) {
+		insert, err = this.pull(code, last.Date, table.Handler(c))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	//4. 插入数据库
+	return tdx.NewSessionFunc(this.DB, func(session *xorm.Session) error {
+		for i, v := range insert {
+			if i == 0 {
+				if _, err := session.Table(table).Where("Code=? and Date >= ?", code, v.Date).Delete(); err != nil {
+					return err
+				}
+			}
+			if _, err := session.Table(table).Insert(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (this *PullKlineMysql) pull(code string, lastDate int64, f func(code string, f func(k *protocol.Kline) bool) (*protocol.KlineResp, error)) (Klines, error) {
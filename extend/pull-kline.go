@@ -2,6 +2,7 @@ package extend
 
 import (
 	"context"
+	"fmt"
 	_ "github.com/glebarez/go-sqlite"
 	"github.com/injoyai/base/chans"
 	"github.com/injoyai/logs"
@@ -10,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 	"xorm.io/core"
 	"xorm.io/xorm"
@@ -80,11 +83,55 @@ func NewPullKline(cfg PullKlineConfig) *PullKline {
 	}
 }
 
+// CheckKlineTables 检查数据类型是否存在,可选的见AllKlineType
+func CheckKlineTables(tables []string) error {
+	for _, v := range tables {
+		if _, ok := KlineTableMap[v]; !ok {
+			return fmt.Errorf("未知的数据类型[%s],可选%s", v, strings.Join(AllKlineType, ","))
+		}
+	}
+	return nil
+}
+
+// RegisterPullKline 按配置文件注册定时拉取k线的任务,在工作日执行,没有开启定时拉取时不注册
+// mysql存储时拉取到Storage.DSN,否则每个代码一个sqlite文件,放在Pull.Dir
+func RegisterPullKline(m *tdx.Manage, c *tdx.Config) error {
+	if !c.Pull.Enabled() {
+		return nil
+	}
+	if err := CheckKlineTables(c.Pull.Tables); err != nil {
+		return err
+	}
+	cfg := PullKlineConfig{
+		Codes:  c.Pull.Codes,
+		Tables: c.Pull.Tables,
+		Dir:    c.Pull.Dir,
+		Limit:  c.Pull.Limit,
+	}
+	var task tdx.Task = NewPullKline(cfg)
+	if c.Storage.Driver == tdx.StorageMysql {
+		cfg.Dir = c.Storage.DSN
+		pull, err := NewPullKlineMysql(cfg)
+		if err != nil {
+			return err
+		}
+		task = pull
+	}
+	return m.Tasks.Register(task, tdx.TaskOption{
+		Spec:    c.Pull.Spec,
+		Workday: true,
+		Retry:   c.Pull.Retry,
+	})
+}
+
 type PullKline struct {
-	tables []*KlineTable
-	Config PullKlineConfig
+	tables   []*KlineTable
+	Config   PullKlineConfig
+	progress atomic.Pointer[tdx.Progress]
 }
 
+var _ tdx.Task = (*PullKline)(nil)
+
 func (this *PullKline) Name() string {
 	return "拉取k线数据"
 }
@@ -104,6 +151,11 @@ func (this *PullKline) DayKlines(code string) (Klines, error) {
 	return data, err
 }
 
+// Progress 当前或最后一次执行的进度,没有执行过返回nil
+func (this *PullKline) Progress() *tdx.Progress {
+	return this.progress.Load()
+}
+
 func (this *PullKline) Run(ctx context.Context, m *tdx.Manage) error {
 	limit := chans.NewWaitLimit(this.Config.Limit)
 
@@ -113,10 +165,17 @@ func (this *PullKline) Run(ctx context.Context, m *tdx.Manage) error {
 		codes = m.Codes.GetStocks()
 	}
 
+	progress, checkpoint, err := newPullProgress(ctx, m, this.Name(), this.tables, codes)
+	if err != nil {
+		return err
+	}
+	this.progress.Store(progress)
+
 	for _, v := range codes {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			limit.Wait()
+			return finishPull(ctx, this.Name(), progress, checkpoint)
 		default:
 		}
 
@@ -124,71 +183,91 @@ func (this *PullKline) Run(ctx context.Context, m *tdx.Manage) error {
 		go func(code string) {
 			defer limit.Done()
 
-			_ = os.MkdirAll(this.Config.Dir, 0777)
-
-			//连接数据库
-			db, err := xorm.NewEngine("sqlite", filepath.Join(this.Config.Dir, code+".db"))
-			if err != nil {
-				logs.Err(err)
-				return
-			}
-			defer db.Close()
-			db.SetMapper(core.SameMapper{})
-			db.DB().SetMaxOpenConns(1)
+			var db *xorm.Engine
+			defer func() {
+				if db != nil {
+					db.Close()
+				}
+			}()
 
 			for _, table := range this.tables {
 				if table == nil {
 					continue
 				}
 
+				//已经完成的,跳过
+				if checkpoint.Done(code, table.TableName()) {
+					progress.Skip(table.TableName(), code)
+					continue
+				}
+
 				select {
 				case <-ctx.Done():
 					return
 				default:
 				}
 
-				logs.PrintErr(db.Sync2(table))
-
-				//2. 获取最后一条数据
-				last := new(Kline)
-				if _, err = db.Table(table).Desc("Date").Get(last); err != nil {
-					logs.Err(err)
-					return
+				//连接数据库,全部完成的不需要连接
+				if db == nil {
+					_ = os.MkdirAll(this.Config.Dir, 0777)
+					engine, err := xorm.NewEngine("sqlite", filepath.Join(this.Config.Dir, code+".db"))
+					if err != nil {
+						progress.Done(table.TableName(), code, err)
+						continue
+					}
+					engine.SetMapper(core.SameMapper{})
+					engine.DB().SetMaxOpenConns(1)
+					db = engine
 				}
 
-				//3. 从服务器获取数据
-				insert := Klines{}
-				err = m.Do(func(c *tdx.Client) error {
-					insert, err = this.pull(code, last.Date, table.Handler(c))
-					return err
-				})
-				if err != nil {
-					logs.Err(err)
-					return
+				err := this.pullTable(m, db, code, table)
+				progress.Done(table.TableName(), code, err)
+				if err == nil {
+					logs.PrintErr(checkpoint.Mark(code, table.TableName()))
 				}
-
-				//4. 插入数据库
-				err = tdx.NewSessionFunc(db, func(session *xorm.Session) error {
-					for i, v := range insert {
-						if i == 0 {
-							if _, err := session.Table(table).Where("Date >= ?", v.Date).Delete(); err != nil {
-								return err
-							}
-						}
-						if _, err := session.Table(table).Insert(v); err != nil {
-							return err
-						}
-					}
-					return nil
-				})
-				logs.PrintErr(err)
-
 			}
 
 		}(v)
 	}
 	limit.Wait()
-	return nil
+	return finishPull(ctx, this.Name(), progress, checkpoint)
+}
+
+func (this *PullKline) pullTable(m *tdx.Manage, db *xorm.Engine, code string, table *KlineTable) error {
+	if err := db.Sync2(table); err != nil {
+		return err
+	}
+
+	//2. 获取最后一条数据
+	last := new(Kline)
+	if _, err := db.Table(table).Desc("Date").Get(last); err != nil {
+		return err
+	}
+
+	//3. 从服务器获取数据
+	insert := Klines{}
+	err := m.Do(func(c *tdx.Client) (err error) {
+		insert, err = this.pull(code, last.Date, table.Handler(c))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	//4. 插入数据库
+	return tdx.NewSessionFunc(db, func(session *xorm.Session) error {
+		for i, v := range insert {
+			if i == 0 {
+				if _, err := session.Table(table).Where("Date >= ?", v.Date).Delete(); err != nil {
+					return err
+				}
+			}
+			if _, err := session.Table(table).Insert(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (this *PullKline) pull(code string, lastDate int64, f func(code string, f func(k *protocol.Kline) bool) (*protocol.KlineResp, error)) (Klines, error) {
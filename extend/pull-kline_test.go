@@ -0,0 +1,12 @@
+package extend
+
+import (
+	"testing"
+)
+
+func TestPullKline_Name(t *testing.T) {
+	//名称是检查点的标识,sqlite和mysql的任务不能共用
+	if a, b := (&PullKline{}).Name(), (&PullKlineMysql{}).Name(); a == b {
+		t.Errorf("任务名称重复: %s", a)
+	}
+}
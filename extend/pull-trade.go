@@ -21,7 +21,7 @@ type PullTrade struct {
 }
 
 func (this *PullTrade) Pull(ctx context.Context, m *tdx.Manage, code string) error {
-	for i := 2000; i <= time.Now().Year(); i++ {
+	for i := 2000; i <= protocol.Now().Year(); i++ {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -179,7 +179,7 @@ func GetTHSDayKline(code string, _type uint8) ([]*Kline, error) {
 			if err != nil {
 				return nil, err
 			}
-			x = time.Date(year, x.Month(), x.Day(), 15, 0, 0, 0, time.Local)
+			x = time.Date(year, x.Month(), x.Day(), 15, 0, 0, 0, protocol.Location)
 			low := protocol.Price(conv.Float64(prices[i*4+0]) * 1000 / priceFactor)
 			ls = append(ls, &Kline{
 				Code:   protocol.AddPrefix(code),
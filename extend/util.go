@@ -2,8 +2,12 @@ package extend
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"github.com/injoyai/conv"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/protocol"
 	"io"
 	"os"
 	"path/filepath"
@@ -56,3 +60,36 @@ func newFile(filename string, v ...interface{}) error {
 	}
 	return nil
 }
+
+// newPullProgress 批量拉取的进度和检查点,检查点以目标交易日(最近一个已经收盘的交易日)为标识,
+// 中断后重新执行会跳过已经完成的(代码,数据表),过了零点重启也能继续
+func newPullProgress(ctx context.Context, m *tdx.Manage, name string, tables []*KlineTable, codes []string) (*tdx.Progress, *tdx.Checkpoint, error) {
+	checkpoint, err := m.Tasks.Checkpoint(name, m.Workday.LastClose(protocol.Now()).Format("20060102"))
+	if err != nil {
+		return nil, nil, err
+	}
+	progress := tdx.NewProgress()
+	progress.OnChange(tdx.TaskRunFrom(ctx).SetProgress)
+	for _, table := range tables {
+		if table != nil {
+			progress.AddTotal(table.TableName(), len(codes))
+		}
+	}
+	if n := checkpoint.Len(); n > 0 {
+		logs.Infof("[%s] 从检查点恢复,跳过%d个已完成的\n", name, n)
+	}
+	return progress, checkpoint, nil
+}
+
+// finishPull 汇总拉取结果,全部成功时清除检查点,有失败时返回*tdx.ProgressError,包含失败的代码
+// 取消时保留检查点,下次执行继续
+func finishPull(ctx context.Context, name string, progress *tdx.Progress, checkpoint *tdx.Checkpoint) error {
+	logs.Infof("[%s] %s\n", name, progress.Snapshot())
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := progress.Err(); err != nil {
+		return err
+	}
+	return checkpoint.Clear()
+}
@@ -0,0 +1,126 @@
+package tdx
+
+import (
+	_ "embed"
+	"fmt"
+	"github.com/injoyai/tdx/protocol"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// holidaysData 内置的休市安排,格式见文件说明
+//
+//go:embed holidays.txt
+var holidaysData string
+
+// DefaultHolidays 默认的休市安排,Workday没有设置时使用,可以通过LoadFile更新
+var DefaultHolidays = NewHolidays()
+
+// NewHolidays 创建休市安排,加载内置的数据
+func NewHolidays() *Holidays {
+	h := &Holidays{years: make(map[int]map[string]bool)}
+	if err := h.Parse(holidaysData); err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// Holidays 交易所公布的休市安排,用于判断未来的交易日
+type Holidays struct {
+	years map[int]map[string]bool //年份->休市日期(20060102)
+	mu    sync.RWMutex
+}
+
+// LoadFile 从本地文件加载休市安排,同一年份覆盖已有的数据
+func (this *Holidays) LoadFile(filename string) error {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return this.Parse(string(bs))
+}
+
+// Parse 解析休市安排,每行是 年份 MMDD MMDD-MMDD ...,#开头是注释,同一年份覆盖已有的数据
+func (this *Holidays) Parse(s string) error {
+	years := make(map[int]map[string]bool)
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		year, err := strconv.Atoi(fields[0])
+		if err != nil || year < 1990 || year > 9999 {
+			return fmt.Errorf("休市安排第%d行的年份错误: %s", i+1, fields[0])
+		}
+		m := make(map[string]bool)
+		for _, v := range fields[1:] {
+			start, end, _ := strings.Cut(v, "-")
+			if end == "" {
+				end = start
+			}
+			t1, err1 := time.ParseInLocation("20060102", fmt.Sprintf("%d%s", year, start), protocol.Location)
+			t2, err2 := time.ParseInLocation("20060102", fmt.Sprintf("%d%s", year, end), protocol.Location)
+			if err1 != nil || err2 != nil || t2.Before(t1) {
+				return fmt.Errorf("休市安排第%d行的日期错误: %s", i+1, v)
+			}
+			for t := t1; !t.After(t2); t = t.AddDate(0, 0, 1) {
+				m[t.Format("20060102")] = true
+			}
+		}
+		years[year] = m
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for k, v := range years {
+		this.years[k] = v
+	}
+	return nil
+}
+
+// Years 已经公布休市安排的年份,正序
+func (this *Holidays) Years() []int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	ls := make([]int, 0, len(this.years))
+	for k := range this.years {
+		ls = append(ls, k)
+	}
+	sort.Ints(ls)
+	return ls
+}
+
+// Is 按休市安排判断是否是交易日(非周末且不休市),known表示这一年的安排是否已经公布,允许this为nil
+func (this *Holidays) Is(t time.Time) (trading bool, known bool) {
+	if this == nil {
+		return false, false
+	}
+	t = IntegerDay(t)
+	this.mu.RLock()
+	m, ok := this.years[t.Year()]
+	this.mu.RUnlock()
+	if !ok {
+		return false, false
+	}
+	if w := t.Weekday(); w == time.Saturday || w == time.Sunday {
+		return false, true
+	}
+	return !m[t.Format("20060102")], true
+}
+
+// CalendarConflict 实际的交易日(指数有日k线)和休市安排不一致
+type CalendarConflict struct {
+	Date     time.Time //日期
+	Observed bool      //实际是否是交易日
+}
+
+func (this CalendarConflict) String() string {
+	if this.Observed {
+		return fmt.Sprintf("%s 休市安排是休市,但实际有交易", this.Date.Format("20060102"))
+	}
+	return fmt.Sprintf("%s 休市安排是交易日,但实际没有交易", this.Date.Format("20060102"))
+}
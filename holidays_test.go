@@ -0,0 +1,84 @@
+package tdx
+
+import (
+	"github.com/injoyai/base/maps"
+	"github.com/injoyai/tdx/protocol"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHolidays(t *testing.T) {
+	date := func(s string) time.Time {
+		t, _ := time.ParseInLocation("20060102", s, protocol.Location)
+		return t
+	}
+
+	h := NewHolidays()
+	for _, v := range []struct {
+		date           string
+		trading, known bool
+	}{
+		{"20241001", false, true},
+		{"20241008", true, true},
+		{"20241012", false, true}, //周六
+		{"20250129", false, true},
+		{"20250603", true, true},
+		{"20200102", false, false},
+	} {
+		trading, known := h.Is(date(v.date))
+		if trading != v.trading || known != v.known {
+			t.Errorf("%s: 期望 %v %v, 得到 %v %v", v.date, v.trading, v.known, trading, known)
+		}
+	}
+
+	//本地文件覆盖同一年份
+	filename := filepath.Join(t.TempDir(), "holidays.txt")
+	if err := os.WriteFile(filename, []byte("# 测试\n2024 0101 1008\n2030 0101\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.LoadFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if trading, _ := h.Is(date("20241008")); trading {
+		t.Errorf("20241008 应该按本地文件休市")
+	}
+	if trading, _ := h.Is(date("20241001")); !trading {
+		t.Errorf("20241001 应该按本地文件是交易日")
+	}
+	if ls := h.Years(); len(ls) == 0 || ls[len(ls)-1] != 2030 {
+		t.Errorf("年份错误: %v", ls)
+	}
+	if err := h.Parse("2024 1301"); err == nil {
+		t.Errorf("应该返回日期错误")
+	}
+}
+
+func TestWorkday_Holidays(t *testing.T) {
+	date := func(s string) time.Time {
+		t, _ := time.ParseInLocation("20060102", s, protocol.Location)
+		return t.Add(time.Hour * 15)
+	}
+
+	w := &Workday{cache: maps.NewBit()}
+	//20240913(周五)之前已经拉取,之后按休市安排判断
+	for _, v := range []string{"20240911", "20240912", "20240913"} {
+		w.set(date(v))
+	}
+	if w.Is(date("20240916")) || w.Is(date("20240917")) || !w.Is(date("20240918")) {
+		t.Errorf("未来的日期应该按休市安排判断")
+	}
+	if next := w.Next(date("20240913")); !next.Equal(IntegerDay(date("20240918"))) {
+		t.Errorf("Next: %s", next)
+	}
+
+	//20240910没有拉取到,20240914是周六却有交易
+	w.set(date("20240914"))
+	ls := []CalendarConflict(nil)
+	w.OnConflict(func(c CalendarConflict) { ls = append(ls, c) })
+	w.alert(w.Check(date("20240910"), date("20240930")))
+	if len(ls) != 2 || ls[0].Observed || !ls[0].Date.Equal(IntegerDay(date("20240910"))) || !ls[1].Observed {
+		t.Errorf("检查结果错误: %v", ls)
+	}
+}
@@ -0,0 +1,93 @@
+package tdx
+
+import (
+	"github.com/injoyai/tdx/protocol"
+	"math"
+)
+
+// Instrument 证券品种信息,用于自动选择k线的解析方式(指数/个股)和价格的缩放倍数
+type Instrument struct {
+	Code    string //带交易所前缀的代码,例sz000001
+	Kind    string //品种,protocol.KindStock,protocol.KindIndex,protocol.KindETF等
+	Decimal int8   //价格小数位,股票和指数是2,基金债券等一般是3
+}
+
+// IsIndex 是否按指数的方式解析k线(多了涨跌家数,成交量需要*100)
+func (this Instrument) IsIndex() bool {
+	return this.Kind == protocol.KindIndex
+}
+
+// Price 服务器返回的价格默认是按2位小数处理的,这里按实际小数位进行缩放
+func (this Instrument) Price(p protocol.Price) protocol.Price {
+	if this.Decimal == 2 || this.Decimal <= 0 {
+		return p
+	}
+	return protocol.Price(float64(p) * math.Pow10(int(2-this.Decimal)))
+}
+
+// Kline 按实际小数位缩放k线的价格
+func (this Instrument) Kline(k *protocol.Kline) {
+	if this.Decimal == 2 || this.Decimal <= 0 {
+		return
+	}
+	k.Last = this.Price(k.Last)
+	k.Open = this.Price(k.Open)
+	k.High = this.Price(k.High)
+	k.Low = this.Price(k.Low)
+	k.Close = this.Price(k.Close)
+}
+
+// Quote 按实际小数位缩放盘口的价格
+func (this Instrument) Quote(q *protocol.Quote) {
+	if this.Decimal == 2 || this.Decimal <= 0 {
+		return
+	}
+	for i, v := range q.SellLevel {
+		q.SellLevel[i].Price = this.Price(v.Price)
+	}
+	for i, v := range q.BuyLevel {
+		q.BuyLevel[i].Price = this.Price(v.Price)
+	}
+	q.K = protocol.K{
+		Last:  this.Price(q.K.Last),
+		Open:  this.Price(q.K.Open),
+		High:  this.Price(q.K.High),
+		Low:   this.Price(q.K.Low),
+		Close: this.Price(q.K.Close),
+	}
+}
+
+// ClassifyInstrument 识别证券品种,优先使用DefaultCodes中的信息(小数位等),未初始化则根据代码规则识别
+func ClassifyInstrument(code string) Instrument {
+	return DefaultCodes.Instrument(code)
+}
+
+// Instrument 识别证券品种,代码规则确定品种,代码信息中的小数位确定价格倍数,允许this为nil
+func (this *Codes) Instrument(code string) Instrument {
+	code = this.AddExchange(code)
+
+	i := Instrument{
+		Code:    code,
+		Kind:    protocol.CodeKind(code),
+		Decimal: 2,
+	}
+
+	switch i.Kind {
+	case protocol.KindStock, protocol.KindIndex:
+		//股票和指数固定是2位小数,北交所的代码是爬虫获取的,没有小数位信息
+
+	default:
+		//基金债券等默认3位小数,未识别的品种默认2位
+		if i.Kind != protocol.KindOther {
+			i.Decimal = 3
+		}
+		if this != nil {
+			if m := this.Get(code); m != nil && m.Decimal > 0 {
+				i.Decimal = m.Decimal
+			}
+		}
+
+	}
+
+	return i
+}
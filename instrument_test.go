@@ -0,0 +1,74 @@
+package tdx
+
+import (
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+)
+
+func TestCodes_Instrument(t *testing.T) {
+	c := &Codes{}
+	c.swap(newCodesSnapshot([]*CodeModel{
+		{Exchange: "sh", Code: "510300", Name: "沪深300ETF", Decimal: 3},
+		{Exchange: "sz", Code: "159915", Name: "创业板ETF", Decimal: 3},
+		{Exchange: "sh", Code: "019547", Name: "国债", Decimal: 3},
+		{Exchange: "sz", Code: "000001", Name: "平安银行", Decimal: 2},
+	}, nil))
+
+	for _, v := range []struct {
+		codes   *Codes
+		code    string
+		kind    string
+		decimal int8
+		index   bool
+	}{
+		{c, "sz000001", protocol.KindStock, 2, false},
+		{c, "sh600000", protocol.KindStock, 2, false},
+		{c, "sh000001", protocol.KindIndex, 2, true},
+		{c, "sz399001", protocol.KindIndex, 2, true},
+		{c, "sh510300", protocol.KindETF, 3, false},
+		{c, "159915", protocol.KindETF, 3, false},
+		{c, "sh019547", protocol.KindBond, 3, false},
+		{c, "sh113050", protocol.KindConvertible, 3, false},
+		//没有代码缓存,按代码规则识别
+		{nil, "sz000001", protocol.KindStock, 2, false},
+		{nil, "sh510300", protocol.KindETF, 3, false},
+		{nil, "sh000300", protocol.KindIndex, 2, true},
+		{nil, "sz127045", protocol.KindConvertible, 3, false},
+	} {
+		i := v.codes.Instrument(v.code)
+		if i.Kind != v.kind || i.Decimal != v.decimal || i.IsIndex() != v.index {
+			t.Errorf("%s 预期%s/%d/%v,得到%s/%d/%v", v.code, v.kind, v.decimal, v.index, i.Kind, i.Decimal, i.IsIndex())
+		}
+	}
+}
+
+func TestInstrument_Price(t *testing.T) {
+	for _, v := range []struct {
+		decimal int8
+		price   protocol.Price
+		want    protocol.Price
+	}{
+		{2, 12340, 12340}, //股票,12.34元
+		{0, 12340, 12340}, //没有小数位信息,不缩放
+		{3, 43210, 4321},  //ETF,按2位小数解析是43.21元,实际是4.321元
+		{4, 43210, 432},
+	} {
+		if p := (Instrument{Decimal: v.decimal}).Price(v.price); p != v.want {
+			t.Errorf("小数位%d: 预期%d,得到%d", v.decimal, v.want, p)
+		}
+	}
+
+	k := &protocol.Kline{Open: 43210, High: 44000, Low: 43000, Close: 43500, Last: 43100}
+	Instrument{Kind: protocol.KindETF, Decimal: 3}.Kline(k)
+	if k.Open != 4321 || k.High != 4400 || k.Low != 4300 || k.Close != 4350 || k.Last != 4310 {
+		t.Errorf("k线缩放错误: %+v", k)
+	}
+
+	q := &protocol.Quote{K: protocol.K{Close: 43500}}
+	q.BuyLevel[0].Price = 43490
+	q.SellLevel[0].Price = 43510
+	Instrument{Kind: protocol.KindETF, Decimal: 3}.Quote(q)
+	if q.K.Close != 4350 || q.BuyLevel[0].Price != 4349 || q.SellLevel[0].Price != 4351 {
+		t.Errorf("盘口缩放错误: %+v", q)
+	}
+}
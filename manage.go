@@ -1,14 +1,23 @@
 package tdx
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/injoyai/conv"
 	"github.com/injoyai/ios/client"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx/protocol"
 	"github.com/robfig/cron/v3"
+	"sync"
 	"time"
+	"xorm.io/xorm"
 )
 
 const (
 	DefaultDatabaseDir = "./data/database"
+
+	DefaultCloseTimeout = time.Second * 30 //Manage.Start的ctx取消后,关闭时等待任务结束的时间
 )
 
 func NewManageMysql(cfg *ManageConfig, op ...client.Option) (*Manage, error) {
@@ -31,7 +40,7 @@ func NewManageMysql(cfg *ManageConfig, op ...client.Option) (*Manage, error) {
 	if err != nil {
 		return nil, err
 	}
-	commonClient.Wait.SetTimeout(time.Second * 5)
+	commonClient.Wait.SetTimeout(conv.Select(cfg.Timeout > 0, cfg.Timeout, time.Second*5))
 
 	//代码管理
 	codes, err := NewCodesMysql(commonClient, cfg.CodesFilename)
@@ -45,21 +54,13 @@ func NewManageMysql(cfg *ManageConfig, op ...client.Option) (*Manage, error) {
 		return nil, err
 	}
 
-	//连接池
-	p, err := NewPool(func() (*Client, error) {
-		return cfg.Dial(op...)
-	}, cfg.Number)
+	//任务的执行记录,默认和代码使用同一个数据库
+	taskDB, err := newMysqlEngine(conv.Select(cfg.TaskFilename == "", cfg.CodesFilename, cfg.TaskFilename))
 	if err != nil {
 		return nil, err
 	}
 
-	return &Manage{
-		Pool:    p,
-		Config:  cfg,
-		Codes:   codes,
-		Workday: workday,
-		Cron:    cron.New(cron.WithSeconds()),
-	}, nil
+	return newManage(cfg, commonClient, codes, workday, taskDB, op...)
 }
 
 func NewManage(cfg *ManageConfig, op ...client.Option) (*Manage, error) {
@@ -73,6 +74,9 @@ func NewManage(cfg *ManageConfig, op ...client.Option) (*Manage, error) {
 	if cfg.WorkdayFileName == "" {
 		cfg.WorkdayFileName = DefaultDatabaseDir + "/workday.db"
 	}
+	if cfg.TaskFilename == "" {
+		cfg.TaskFilename = DefaultDatabaseDir + "/task.db"
+	}
 	if cfg.Dial == nil {
 		cfg.Dial = DialDefault
 	}
@@ -82,43 +86,196 @@ func NewManage(cfg *ManageConfig, op ...client.Option) (*Manage, error) {
 	if err != nil {
 		return nil, err
 	}
-	commonClient.Wait.SetTimeout(time.Second * 5)
+	commonClient.Wait.SetTimeout(conv.Select(cfg.Timeout > 0, cfg.Timeout, time.Second*5))
 
-	//代码管理
-	codes, err := NewCodesSqlite(commonClient, cfg.CodesFilename)
+	//代码管理,配置了存储则使用配置的存储
+	var codes *Codes
+	if cfg.CodeStore != nil {
+		codes, err = NewCodesStore(commonClient, cfg.CodeStore)
+	} else {
+		codes, err = NewCodesSqlite(commonClient, cfg.CodesFilename)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	//工作日管理
-	workday, err := NewWorkdaySqlite(commonClient, cfg.WorkdayFileName)
+	var workday *Workday
+	if cfg.CalendarStore != nil {
+		workday, err = NewWorkdayStore(commonClient, cfg.CalendarStore)
+	} else {
+		workday, err = NewWorkdaySqlite(commonClient, cfg.WorkdayFileName)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	//任务的执行记录
+	taskDB, err := newSqliteEngine(cfg.TaskFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	return newManage(cfg, commonClient, codes, workday, taskDB, op...)
+}
+
+func newManage(cfg *ManageConfig, commonClient *Client, codes *Codes, workday *Workday, taskDB *xorm.Engine, op ...client.Option) (*Manage, error) {
+
 	//连接池
 	p, err := NewPool(func() (*Client, error) {
-		return cfg.Dial(op...)
+		c, err := cfg.Dial(op...)
+		if err == nil && cfg.Timeout > 0 {
+			c.Wait.SetTimeout(cfg.Timeout)
+		}
+		return c, err
 	}, cfg.Number)
 	if err != nil {
 		return nil, err
 	}
+	p.SetRateLimit(cfg.RateLimit)
+
+	//加载本地的休市安排
+	if cfg.HolidaysFilename != "" {
+		h := NewHolidays()
+		if err := h.LoadFile(cfg.HolidaysFilename); err != nil {
+			return nil, err
+		}
+		workday.SetHolidays(h)
+	}
+
+	//定时更新工作日和代码
+	refresher, err := newManageRefresher(cfg, codes, workday)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manage{
+		Pool:      p,
+		Config:    cfg,
+		Codes:     codes,
+		Workday:   workday,
+		Cron:      cron.New(cron.WithSeconds(), cron.WithLocation(protocol.Location)),
+		Refresher: refresher,
+		client:    commonClient,
+		taskDB:    taskDB,
+		closing:   make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+
+	//任务执行器
+	m.Tasks, err = NewTaskRunner(m, taskDB)
+	if err != nil {
+		return nil, err
+	}
 
-	return &Manage{
-		Pool:    p,
-		Config:  cfg,
-		Codes:   codes,
-		Workday: workday,
-		Cron:    cron.New(cron.WithSeconds()),
-	}, nil
+	return m, nil
 }
 
 type Manage struct {
 	*Pool
-	Config  *ManageConfig
-	Codes   *Codes
-	Workday *Workday
-	Cron    *cron.Cron
+	Config    *ManageConfig
+	Codes     *Codes
+	Workday   *Workday
+	Cron      *cron.Cron
+	Refresher *Refresher  //定时更新代码和工作日
+	Tasks     *TaskRunner //任务执行器,注册的任务定时执行需要启动Cron
+
+	client    *Client       //Codes和Workday共用的客户端
+	taskDB    *xorm.Engine  //任务执行记录的数据库
+	closing   chan struct{} //开始关闭的信号
+	closed    chan struct{} //关闭完成的信号
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Start 启动定时任务(Cron),注册的定时任务(Tasks.Register,AddWorkdayTask)开始执行
+// ctx取消时自动关闭,最多等待DefaultCloseTimeout,也可以直接调用Close
+func (this *Manage) Start(ctx context.Context) error {
+	select {
+	case <-this.closing:
+		return errors.New("manage closed")
+	default:
+	}
+	this.Cron.Start()
+	go func() {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultCloseTimeout)
+			defer cancel()
+			logs.PrintErr(this.Close(ctx))
+		case <-this.closing:
+		}
+	}()
+	return nil
+}
+
+// Close 关闭,停止定时任务,取消执行中的任务(例如拉取k线)和更新并等待结束,最多等到ctx的截止时间,
+// 然后断开连接池和客户端,关闭数据库,避免程序退出时数据库写入了一半,重复调用返回第一次的结果
+// 不是通过Tasks执行的任务需要自己取消
+func (this *Manage) Close(ctx context.Context) error {
+	this.closeOnce.Do(func() {
+		close(this.closing)
+		this.closeErr = this.close(ctx)
+		close(this.closed)
+	})
+	return this.closeErr
+}
+
+// Done 关闭完成的信号
+func (this *Manage) Done() <-chan struct{} {
+	return this.closed
+}
+
+func (this *Manage) close(ctx context.Context) error {
+	errs := []error(nil)
+
+	//1. 停止定时触发,取消执行中的任务,同时进行
+	cronCtx := this.Cron.Stop()
+	wg := sync.WaitGroup{}
+	for _, f := range []func() error{this.Tasks.Close, this.Refresher.Close} {
+		wg.Add(1)
+		go func(f func() error) {
+			defer wg.Done()
+			logs.PrintErr(f())
+		}(f)
+	}
+	wait := make(chan struct{})
+	go func() {
+		wg.Wait()
+		<-cronCtx.Done()
+		close(wait)
+	}()
+
+	//2. 等待结束,超时了继续关闭,数据库会等待执行中的语句结束
+	select {
+	case <-wait:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("等待任务结束超时: %w", ctx.Err()))
+	}
+
+	//3. 先断开客户端,让还在请求的任务尽快失败,再关闭数据库
+	errs = append(errs, this.Pool.Close())
+	if this.client != nil {
+		errs = append(errs, this.client.CloseAll())
+	}
+	errs = append(errs, this.Codes.Close(), this.Workday.Close())
+	if this.taskDB != nil {
+		errs = append(errs, this.taskDB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func newManageRefresher(cfg *ManageConfig, codes *Codes, workday *Workday) (*Refresher, error) {
+	r := NewRefresher(cfg.Refresh)
+	if err := r.AddWorkday(workday, cfg.WorkdaySpec); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := r.AddCodes(codes, cfg.CodesSpec); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
 }
 
 // RangeStocks 遍历所有股票
@@ -145,8 +302,17 @@ func (this *Manage) AddWorkdayTask(spec string, f func(m *Manage)) {
 }
 
 type ManageConfig struct {
-	Number          int                                                //客户端数量
-	CodesFilename   string                                             //代码数据库位置
-	WorkdayFileName string                                             //工作日数据库位置
-	Dial            func(op ...client.Option) (cli *Client, err error) //默认连接方式
+	Number           int                                                //客户端数量
+	CodesFilename    string                                             //代码数据库位置
+	WorkdayFileName  string                                             //工作日数据库位置
+	Dial             func(op ...client.Option) (cli *Client, err error) //默认连接方式
+	CodeStore        CodeStore                                          //代码的存储,优先于CodesFilename,例如NewCodeStoreMemory
+	CalendarStore    CalendarStore                                      //工作日的存储,优先于WorkdayFileName
+	CodesSpec        string                                             //代码的更新时间,cron表达式(带秒),默认DefaultCodesSpec
+	WorkdaySpec      string                                             //工作日的更新时间,默认DefaultWorkdaySpec
+	Refresh          RefresherConfig                                    //定时更新的重试和随机延迟
+	HolidaysFilename string                                             //本地的休市安排文件,覆盖内置的数据,格式见holidays.txt
+	TaskFilename     string                                             //任务执行记录的数据库位置,mysql默认和CodesFilename相同
+	Timeout          time.Duration                                      //请求的超时时间,默认通用客户端5秒,连接池2秒
+	RateLimit        float64                                            //连接池(Pool)每秒最多请求次数,0不限制,不使用连接池的请求不限速
 }
@@ -0,0 +1,94 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"github.com/injoyai/base/maps"
+	"github.com/injoyai/tdx/protocol"
+	"github.com/robfig/cron/v3"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestManage 不连接服务器的Manage,用于测试生命周期
+func newTestManage(t *testing.T) *Manage {
+	db, err := newSqliteEngine(filepath.Join(t.TempDir(), "task.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manage{
+		Pool:      newPool(1),
+		Codes:     &Codes{store: NewCodeStoreMemory()},
+		Workday:   &Workday{store: NewCalendarStoreMemory(), cache: maps.NewBit()},
+		Cron:      cron.New(cron.WithSeconds(), cron.WithLocation(protocol.Location)),
+		Refresher: NewRefresher(RefresherConfig{}),
+		taskDB:    db,
+		closing:   make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	if m.Tasks, err = NewTaskRunner(m, db); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManageClose(t *testing.T) {
+	m := newTestManage(t)
+	started := make(chan struct{})
+	m.Tasks.Register(&testTask{name: "pull", run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}, TaskOption{})
+
+	//ctx取消时自动关闭,取消执行中的任务
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m.Tasks.Go("pull")
+	<-started
+	cancel()
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("关闭超时")
+	}
+	if last, _ := m.Tasks.Last("pull"); last == nil || last.Status != TaskCanceled {
+		t.Errorf("任务应该被取消: %+v", last)
+	}
+	if err := m.taskDB.Ping(); err == nil {
+		t.Errorf("数据库应该已经关闭")
+	}
+	if _, err := m.Pool.Get(); err == nil {
+		t.Errorf("连接池应该已经关闭")
+	}
+	if err := m.Start(context.Background()); err == nil {
+		t.Errorf("关闭之后不能再启动")
+	}
+}
+
+func TestManageCloseTimeout(t *testing.T) {
+	m := newTestManage(t)
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	m.Tasks.Register(&testTask{name: "stuck", run: func(ctx context.Context) error {
+		close(started)
+		<-release //不响应取消
+		return nil
+	}}, TaskOption{})
+	m.Tasks.Go("stuck")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := m.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("应该返回超时: %v", err)
+	}
+	//重复调用返回第一次的结果
+	if err := m.Close(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("重复关闭的结果错误: %v", err)
+	}
+}
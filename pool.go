@@ -1,26 +1,22 @@
 package tdx
 
 import (
-	"errors"
 	"github.com/injoyai/base/safe"
+	"sync"
+	"time"
 )
 
 // NewPool 简易版本的连接池
+// 关闭时断开空闲的客户端,使用中的客户端在放回时断开
 func NewPool(dial func() (*Client, error), number int) (*Pool, error) {
 	if number <= 0 {
 		number = 1
 	}
-	ch := make(chan *Client, number)
-	p := &Pool{
-		ch: ch,
-		Closer: safe.NewCloser().SetCloseFunc(func(err error) error {
-			close(ch)
-			return nil
-		}),
-	}
+	p := newPool(number)
 	for i := 0; i < number; i++ {
 		c, err := dial()
 		if err != nil {
+			p.Close()
 			return nil, err
 		}
 		p.ch <- c
@@ -28,18 +24,74 @@ func NewPool(dial func() (*Client, error), number int) (*Pool, error) {
 	return p, nil
 }
 
+func newPool(number int) *Pool {
+	p := &Pool{
+		ch: make(chan *Client, number),
+	}
+	p.Closer = safe.NewCloser().SetCloseFunc(func(err error) error {
+		p.drain()
+		return nil
+	})
+	return p
+}
+
 type Pool struct {
 	ch chan *Client
 	*safe.Closer
+
+	interval time.Duration //限速,两次获取客户端的最小间隔
+	next     time.Time     //下次可以获取的时间
+	rateMu   sync.Mutex
+}
+
+// SetRateLimit 限速,每秒最多获取n次客户端(即请求次数),n<=0不限制
+func (this *Pool) SetRateLimit(n float64) {
+	this.rateMu.Lock()
+	defer this.rateMu.Unlock()
+	this.interval = 0
+	if n > 0 {
+		this.interval = time.Duration(float64(time.Second) / n)
+	}
+}
+
+// wait 等待限速,关闭时返回错误
+func (this *Pool) wait() error {
+	this.rateMu.Lock()
+	if this.interval <= 0 {
+		this.rateMu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if this.next.Before(now) {
+		this.next = now
+	}
+	d := this.next.Sub(now)
+	this.next = this.next.Add(this.interval)
+	this.rateMu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-this.Done():
+		return this.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 func (this *Pool) Get() (*Client, error) {
+	if err := this.wait(); err != nil {
+		return nil, err
+	}
 	select {
 	case <-this.Done():
 		return nil, this.Err()
-	case c, ok := <-this.ch:
-		if !ok {
-			return nil, errors.New("已关闭")
+	case c := <-this.ch:
+		if this.Closed() {
+			c.CloseAll()
+			return nil, this.Err()
 		}
 		return c, nil
 	}
@@ -48,9 +100,24 @@ func (this *Pool) Get() (*Client, error) {
 func (this *Pool) Put(c *Client) {
 	select {
 	case <-this.Done():
-		c.Close()
-		return
+		c.CloseAll()
 	case this.ch <- c:
+		//放回的同时关闭了
+		if this.Closed() {
+			this.drain()
+		}
+	}
+}
+
+// drain 断开空闲的客户端,不再重连
+func (this *Pool) drain() {
+	for {
+		select {
+		case c := <-this.ch:
+			c.CloseAll()
+		default:
+			return
+		}
 	}
 }
 
@@ -0,0 +1,46 @@
+package tdx
+
+import (
+	"github.com/injoyai/tdx/protocol"
+	"time"
+)
+
+// LimitRule 代码的涨跌幅限制规则,只使用ListDate作为上市日期
+// FirstSeen不能当作上市日期,例如升级后新增到数据库的老代码,FirstSeen是当天
+func (this *CodeModel) LimitRule() protocol.LimitRule {
+	r := protocol.LimitRule{
+		Code: this.FullCode(),
+		Name: this.Name,
+	}
+	if this.ListDate > 0 {
+		r.ListDate = time.Unix(this.ListDate, 0).In(protocol.Location)
+	}
+	return r
+}
+
+// LimitRule 获取代码的涨跌幅限制规则,没有代码信息时只按代码规则识别,允许this为nil
+func (this *Codes) LimitRule(code string) protocol.LimitRule {
+	code = this.AddExchange(code)
+	if this != nil {
+		if m := this.Get(code); m != nil {
+			return m.LimitRule()
+		}
+	}
+	return protocol.LimitRule{Code: code}
+}
+
+// PriceLimit 计算代码在date这天的涨跌停价,last是上一个交易日的收盘价,按date这天的规则和名称(ST)判断
+// w不为nil且知道上市日期(见FillListDate)时,按交易日计算新股没有涨跌幅限制的天数,否则按名称(N,C开头)识别
+func (this *Codes) PriceLimit(code string, last protocol.Price, date time.Time, w *Workday) protocol.PriceLimit {
+	rule := this.LimitRule(code)
+	if this != nil {
+		if name := this.NameAt(code, date); name != "" {
+			rule.Name = name
+		}
+	}
+	day := 0
+	if w != nil && !rule.ListDate.IsZero() {
+		day = w.CountBetween(rule.ListDate, date)
+	}
+	return rule.Limit(last, date, day)
+}
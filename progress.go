@@ -0,0 +1,185 @@
+package tdx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewProgress 批量任务的进度,按数据表统计完成数量,记录失败的代码,估算剩余时间
+func NewProgress() *Progress {
+	return &Progress{
+		start:  time.Now(),
+		tables: make(map[string]*ProgressTable),
+	}
+}
+
+type Progress struct {
+	start     time.Time
+	tables    map[string]*ProgressTable
+	order     []string
+	failures  []ProgressFailure
+	processed int //这次实际执行的数量,不包含从检查点跳过的,用于估算剩余时间
+	onChange  []func(done, total int)
+	mu        sync.RWMutex
+}
+
+// ProgressTable 单个数据表的进度
+type ProgressTable struct {
+	Table   string `json:"table"`
+	Total   int    `json:"total"`   //总数量
+	Done    int    `json:"done"`    //已完成的数量,包含失败和跳过的
+	Failed  int    `json:"failed"`  //失败的数量
+	Skipped int    `json:"skipped"` //从检查点恢复跳过的数量
+}
+
+// ProgressFailure 失败的记录
+type ProgressFailure struct {
+	Code  string `json:"code"`
+	Table string `json:"table"`
+	Error string `json:"error"`
+}
+
+// ProgressSnapshot 进度的快照
+type ProgressSnapshot struct {
+	Tables   []ProgressTable   `json:"tables"`   //每个数据表的进度
+	Total    int               `json:"total"`    //总数量
+	Done     int               `json:"done"`     //已完成的数量
+	Failed   int               `json:"failed"`   //失败的数量
+	Elapsed  time.Duration     `json:"elapsed"`  //已用时间
+	ETA      time.Duration     `json:"eta"`      //预计剩余时间,还没有完成任何一个时为0
+	Failures []ProgressFailure `json:"failures"` //失败的记录
+}
+
+func (this ProgressSnapshot) String() string {
+	s := fmt.Sprintf("进度: %d/%d, 失败: %d, 用时: %s", this.Done, this.Total, this.Failed, this.Elapsed.Round(time.Second))
+	if this.ETA > 0 {
+		s += fmt.Sprintf(", 剩余: %s", this.ETA.Round(time.Second))
+	}
+	return s
+}
+
+// OnChange 进度变化时回调,例如Progress.OnChange(TaskRunFrom(ctx).SetProgress)
+func (this *Progress) OnChange(f func(done, total int)) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.onChange = append(this.onChange, f)
+}
+
+func (this *Progress) table(name string) *ProgressTable {
+	t, ok := this.tables[name]
+	if !ok {
+		t = &ProgressTable{Table: name}
+		this.tables[name] = t
+		this.order = append(this.order, name)
+	}
+	return t
+}
+
+// AddTotal 增加数据表的总数量
+func (this *Progress) AddTotal(table string, n int) {
+	this.mu.Lock()
+	this.table(table).Total += n
+	this.mu.Unlock()
+	this.notify()
+}
+
+// Skip 从检查点恢复,跳过已经完成的
+func (this *Progress) Skip(table, code string) {
+	this.mu.Lock()
+	t := this.table(table)
+	t.Done++
+	t.Skipped++
+	this.mu.Unlock()
+	this.notify()
+}
+
+// Done 完成一个,err不为空记录为失败
+func (this *Progress) Done(table, code string, err error) {
+	this.mu.Lock()
+	t := this.table(table)
+	t.Done++
+	this.processed++
+	if err != nil {
+		t.Failed++
+		this.failures = append(this.failures, ProgressFailure{Code: code, Table: table, Error: err.Error()})
+	}
+	this.mu.Unlock()
+	this.notify()
+}
+
+func (this *Progress) notify() {
+	this.mu.RLock()
+	fs := this.onChange
+	done, total := 0, 0
+	for _, t := range this.tables {
+		done += t.Done
+		total += t.Total
+	}
+	this.mu.RUnlock()
+	for _, f := range fs {
+		f(done, total)
+	}
+}
+
+// Snapshot 当前的进度
+func (this *Progress) Snapshot() ProgressSnapshot {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	s := ProgressSnapshot{
+		Elapsed:  time.Since(this.start),
+		Failures: append([]ProgressFailure(nil), this.failures...),
+	}
+	for _, name := range this.order {
+		t := *this.tables[name]
+		s.Tables = append(s.Tables, t)
+		s.Total += t.Total
+		s.Done += t.Done
+		s.Failed += t.Failed
+	}
+	if this.processed > 0 && s.Total > s.Done {
+		s.ETA = s.Elapsed / time.Duration(this.processed) * time.Duration(s.Total-s.Done)
+	}
+	return s
+}
+
+// FailedCodes 失败的代码,去重后排序,用于重新拉取
+func (this *Progress) FailedCodes() []string {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	m := make(map[string]bool)
+	ls := []string(nil)
+	for _, v := range this.failures {
+		if !m[v.Code] {
+			m[v.Code] = true
+			ls = append(ls, v.Code)
+		}
+	}
+	sort.Strings(ls)
+	return ls
+}
+
+// Err 有失败的记录时返回*ProgressError,否则返回nil
+func (this *Progress) Err() error {
+	s := this.Snapshot()
+	if len(s.Failures) == 0 {
+		return nil
+	}
+	return &ProgressError{Failures: s.Failures, Codes: this.FailedCodes()}
+}
+
+// ProgressError 批量任务部分失败
+type ProgressError struct {
+	Failures []ProgressFailure //失败的记录
+	Codes    []string          //失败的代码,可以重新拉取
+}
+
+func (this *ProgressError) Error() string {
+	codes := this.Codes
+	if len(codes) > 20 {
+		codes = append(codes[:20:20], "...")
+	}
+	return fmt.Sprintf("%d条失败,代码(%d个): %s", len(this.Failures), len(this.Codes), strings.Join(codes, ","))
+}
@@ -0,0 +1,79 @@
+package tdx
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	p := NewProgress()
+	last := [2]int{}
+	p.OnChange(func(done, total int) { last = [2]int{done, total} })
+	p.AddTotal("DayKline", 3)
+	p.AddTotal("WeekKline", 3)
+	p.Skip("DayKline", "sz000001")
+	p.Done("DayKline", "sz000002", nil)
+	p.Done("DayKline", "sh600000", errors.New("超时"))
+	p.Done("WeekKline", "sh600000", errors.New("超时"))
+	p.Done("WeekKline", "sz000001", errors.New("超时"))
+
+	s := p.Snapshot()
+	if s.Total != 6 || s.Done != 5 || s.Failed != 3 || len(s.Tables) != 2 || s.Tables[0].Skipped != 1 {
+		t.Errorf("进度错误: %+v", s)
+	}
+	if last != [2]int{5, 6} {
+		t.Errorf("回调进度错误: %v", last)
+	}
+	if s.ETA <= 0 {
+		t.Errorf("剩余时间应该大于0: %v", s.ETA)
+	}
+	err, ok := p.Err().(*ProgressError)
+	if !ok || len(err.Failures) != 3 || len(err.Codes) != 2 || err.Codes[0] != "sh600000" {
+		t.Errorf("失败汇总错误: %v", p.Err())
+	}
+	if NewProgress().Err() != nil {
+		t.Errorf("没有失败应该返回nil")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	db, err := newSqliteEngine(filepath.Join(t.TempDir(), "task.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c, err := NewCheckpoint(db, "pull", "20250102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Mark("sz000001", "DayKline")
+	c.Mark("sz000001", "DayKline")
+	c.Mark("sz000002", "DayKline")
+
+	//中断后重新执行,恢复已完成的
+	c, err = NewCheckpoint(db, "pull", "20250102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 2 || !c.Done("sz000001", "DayKline") || c.Done("sz000001", "WeekKline") {
+		t.Errorf("恢复检查点错误: %d", c.Len())
+	}
+
+	//标识变化,之前的检查点失效
+	c, err = NewCheckpoint(db, "pull", "20250103")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("标识变化后应该从头开始: %d", c.Len())
+	}
+	c.Mark("sz000001", "DayKline")
+	if err := c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.Count(new(CheckpointModel)); n != 0 || c.Len() != 0 {
+		t.Errorf("清除之后应该为空: %d", n)
+	}
+}
@@ -16,10 +16,28 @@ const (
 )
 
 var (
+	// Location 交易所的时区(北京时间),解码的时间,工作日,k线时间修复等都统一使用该时区,与服务器的本地时区无关
+	// 使用固定的东八区,和Asia/Shanghai一致(1991年之后无夏令时),不依赖系统的时区数据库,修改请使用SetLocation
+	Location = time.FixedZone("CST", 8*3600)
+
 	// ExchangeEstablish 交易所成立时间
-	ExchangeEstablish = time.Date(1990, 12, 19, 0, 0, 0, 0, time.Local)
+	ExchangeEstablish = time.Date(1990, 12, 19, 0, 0, 0, 0, Location)
 )
 
+// SetLocation 设置交易所的时区,需要在使用前设置
+func SetLocation(loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	Location = loc
+	ExchangeEstablish = time.Date(1990, 12, 19, 0, 0, 0, 0, loc)
+}
+
+// Now 交易所时区的当前时间
+func Now() time.Time {
+	return time.Now().In(Location)
+}
+
 /*
 从其他地方复制
 const (
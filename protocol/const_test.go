@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+// testLocations 模拟服务器运行在不同的时区
+var testLocations = []*time.Location{
+	time.UTC,
+	time.FixedZone("UTC-5", -5*3600),
+	time.FixedZone("UTC+8", 8*3600),
+	time.FixedZone("UTC+14", 14*3600),
+}
+
+func withLocal(t *testing.T, f func(t *testing.T)) {
+	old := time.Local
+	defer func() { time.Local = old }()
+	for _, loc := range testLocations {
+		time.Local = loc
+		t.Run(loc.String(), f)
+	}
+}
+
+func TestGetTime_Location(t *testing.T) {
+	withLocal(t, func(t *testing.T) {
+		//日线 20241028 15:00
+		day := GetTime([4]byte(Bytes(uint32(20241028))), TypeKlineDay)
+		if day.Unix() != 1730098800 || day.Hour() != 15 {
+			t.Errorf("日线时间错误: %s(%d)", day, day.Unix())
+		}
+
+		//分钟线 20241028 11:30
+		var bs [4]byte
+		copy(bs[:2], Bytes(uint16((2024-2004)<<11+1028)))
+		copy(bs[2:], Bytes(uint16(11*60+30)))
+		minute := GetTime(bs, TypeKlineMinute)
+		if minute.Unix() != 1730086200 || minute.Hour() != 11 {
+			t.Errorf("分钟线时间错误: %s(%d)", minute, minute.Unix())
+		}
+	})
+}
+
+func TestTrades_Klines_Location(t *testing.T) {
+	withLocal(t, func(t *testing.T) {
+		ts := Trades{
+			{Time: time.Date(2024, 10, 28, 9, 25, 0, 0, Location), Price: 11000, Volume: 10},
+			{Time: time.Date(2024, 10, 28, 14, 59, 0, 0, Location), Price: 11100, Volume: 10},
+		}
+		ks := ts.Klines()
+		if len(ks) != 240 {
+			t.Errorf("预期240条k线,得到%d", len(ks))
+			return
+		}
+		first, last := ks[0].Time.In(Location), ks[len(ks)-1].Time.In(Location)
+		if first.Format("20060102 15:04") != "20241028 09:31" || last.Format("20060102 15:04") != "20241028 15:00" {
+			t.Errorf("k线时间错误: %s - %s", first, last)
+		}
+	})
+}
@@ -42,7 +42,7 @@ func (this historyMinute) Decode(bs []byte) (*MinuteResp, error) {
 	bs = bs[6:]
 
 	lastPrice := Price(0)
-	t := time.Date(0, 0, 0, 9, 30, 0, 0, time.Local)
+	t := time.Date(0, 0, 0, 9, 30, 0, 0, Location)
 	for i := uint16(0); i < resp.Count; i++ {
 		var price Price
 		bs, price = GetPrice(bs)
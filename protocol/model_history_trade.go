@@ -7,11 +7,6 @@ import (
 	"github.com/injoyai/conv"
 )
 
-var (
-	// 中国标准时间时区 (UTC+8) - 用于历史分时成交
-	locationCSTHistory = time.FixedZone("CST", 8*3600)
-)
-
 // HistoryTradeResp 兼容之前的版本
 type HistoryTradeResp = TradeResp
 
@@ -54,8 +49,8 @@ func (historyTrade) Decode(bs []byte, c TradeCache) (*TradeResp, error) {
 	lastPrice := Price(0)
 	for i := uint16(0); i < resp.Count; i++ {
 		timeStr := GetHourMinute([2]byte(bs[:2]))
-		// 数据中的时间本身就是北京时间，使用CST时区解析
-		t, err := time.ParseInLocation("2006010215:04", c.Date+timeStr, locationCSTHistory)
+		// 数据中的时间本身就是北京时间，使用交易所时区解析
+		t, err := time.ParseInLocation("2006010215:04", c.Date+timeStr, Location)
 		if err != nil {
 			return nil, err
 		}
@@ -66,7 +61,7 @@ func (historyTrade) Decode(bs []byte, c TradeCache) (*TradeResp, error) {
 		mt.Price = lastPrice / basePrice(number)
 		bs, mt.Volume = CutInt(bs)
 		bs, mt.Status = CutInt(bs)
-		bs, _ = CutInt(bs) //这个得到的是0，不知道是啥
+		bs, mt.Unknown = CutInt(bs) //这个得到的基本是0，不知道是啥
 		resp.List = append(resp.List, mt)
 	}
 
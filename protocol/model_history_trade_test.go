@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"testing"
+	"time"
 )
 
 func Test_stockHistoryMinuteTrade_Frame(t *testing.T) {
@@ -14,3 +15,71 @@ func Test_stockHistoryMinuteTrade_Frame(t *testing.T) {
 	}
 	t.Log(f.Bytes().HEX())
 }
+
+func TestTrades_Merge(t *testing.T) {
+	date := time.Date(2024, 10, 28, 9, 30, 0, 0, Location)
+	all := Trades(nil)
+	for i := 0; i < 50; i++ {
+		//同一分钟内有多条相同的成交
+		all = append(all, &Trade{Time: date.Add(time.Minute * time.Duration(i/5)), Price: Price(10000 + i%3), Volume: 1 + i%2})
+	}
+
+	old := copyTrades(all[:30]).Sequence(1)
+
+	//新数据和已有数据有重叠
+	add, err := old.Merge(copyTrades(all[20:]))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(add) != 20 || add[0].Seq != 31 || add[len(add)-1].Seq != 50 {
+		t.Errorf("合并错误,新增%d条", len(add))
+	}
+
+	//新数据和已有数据没有重叠
+	if _, err = old.Merge(copyTrades(all[35:])); err != ErrTradeOverlap {
+		t.Errorf("预期没有重叠: %v", err)
+	}
+
+	//重叠太少,只有最后2条重叠
+	if _, err = old.Merge(copyTrades(all[28:29])); err != ErrTradeNewerFew {
+		t.Errorf("预期新数据太少: %v", err)
+	}
+
+	//没有已有数据,不能确定序号
+	if _, err = Trades(nil).Merge(copyTrades(all)); err != ErrTradeEmpty {
+		t.Errorf("预期没有已有数据: %v", err)
+	}
+	if _, err = copyTrades(all[:2]).Merge(copyTrades(all)); err != ErrTradeTooFew {
+		t.Errorf("预期已有数据太少: %v", err)
+	}
+
+	//已有数据没有序号,新增的数据也没有序号
+	add, err = copyTrades(all[:30]).Merge(copyTrades(all[25:]))
+	if err != nil || len(add) != 20 || add[0].Seq != 0 || add[0].Key() != "" {
+		t.Errorf("没有序号的合并错误: %d %v", len(add), err)
+	}
+}
+
+func TestTrade_Key(t *testing.T) {
+	date := time.Date(2024, 10, 28, 9, 30, 0, 0, Location)
+	a := &Trade{Time: date, Price: 10000, Volume: 1}
+	b := &Trade{Time: date, Price: 10000, Volume: 1}
+	if a.Key() != "" {
+		t.Errorf("没有序号应该返回空: %s", a.Key())
+	}
+	Trades{a, b}.Sequence(1)
+	if a.Key() != "20241028#1" || b.Key() != "20241028#2" {
+		t.Errorf("相同内容的成交应该有不同的标识: %s %s", a.Key(), b.Key())
+	}
+}
+
+// copyTrades 复制成交,避免修改序号影响原数据
+func copyTrades(ls Trades) Trades {
+	result := Trades(nil)
+	for _, v := range ls {
+		c := *v
+		result = append(result, &c)
+	}
+	return result
+}
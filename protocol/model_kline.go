@@ -16,7 +16,7 @@ type KlineReq struct {
 	Count    uint16
 }
 
-func (this *KlineReq) Bytes(Type uint8) (types.Bytes, error) {
+func (this *KlineReq) Bytes(Type KlineType) (types.Bytes, error) {
 	if this.Count > 800 {
 		return nil, errors.New("单次数量不能超过800")
 	}
@@ -25,7 +25,7 @@ func (this *KlineReq) Bytes(Type uint8) (types.Bytes, error) {
 	}
 	data := []byte{this.Exchange.Uint8(), 0x0}
 	data = append(data, []byte(this.Code)...) //这里怎么是正序了？
-	data = append(data, Type, 0x0)
+	data = append(data, Type.Uint8(), 0x0)
 	data = append(data, 0x01, 0x0)
 	data = append(data, Bytes(this.Start)...)
 	data = append(data, Bytes(this.Count)...)
@@ -107,7 +107,7 @@ Start: a401
 Count: a401
 Append: 00000000000000000000
 */
-func (kline) Frame(Type uint8, code string, start, count uint16) (*Frame, error) {
+func (kline) Frame(Type KlineType, code string, start, count uint16) (*Frame, error) {
 	if count > 800 {
 		return nil, errors.New("单次数量不能超过800")
 	}
@@ -119,7 +119,7 @@ func (kline) Frame(Type uint8, code string, start, count uint16) (*Frame, error)
 
 	data := []byte{exchange.Uint8(), 0x0}
 	data = append(data, []byte(number)...) //这里怎么是正序了？
-	data = append(data, Type, 0x0)
+	data = append(data, Type.Uint8(), 0x0)
 	data = append(data, 0x01, 0x0)
 	data = append(data, Bytes(start)...)
 	data = append(data, Bytes(count)...)
@@ -180,8 +180,7 @@ func (kline) Decode(bs []byte, c KlineCache) (*KlineResp, error) {
 		*/
 		k.Volume = int64(getVolume(Uint32(bs[:4])))
 		bs = bs[4:]
-		switch c.Type {
-		case TypeKlineMinute, TypeKline5Minute, TypeKlineMinute2, TypeKline15Minute, TypeKline30Minute, TypeKline60Minute, TypeKlineDay2:
+		if c.Type.IsMinute() || c.Type == TypeKlineDay2 {
 			k.Volume /= 100
 		}
 		k.Amount = Price(getVolume(Uint32(bs[:4])) * 1000) //从元转为厘,并去除多余的小数
@@ -203,8 +202,8 @@ func (kline) Decode(bs []byte, c KlineCache) (*KlineResp, error) {
 }
 
 type KlineCache struct {
-	Type uint8  //1分钟,5分钟,日线等
-	Kind string //指数,个股等
+	Type KlineType //1分钟,5分钟,日线等
+	Kind string    //指数,个股等
 }
 
 // FixKlineTime 修复盘内下午(13~15点)拉取数据的时候,11.30的时间变成13.00
@@ -212,10 +211,10 @@ func FixKlineTime(ks []*Kline) []*Kline {
 	if len(ks) == 0 {
 		return ks
 	}
-	now := time.Now()
+	now := Now()
 	//只有当天下午13~15点之间才会出现的时间问题
-	node1 := time.Date(now.Year(), now.Month(), now.Day(), 13, 0, 0, 0, now.Location())
-	node2 := time.Date(now.Year(), now.Month(), now.Day(), 15, 0, 0, 0, now.Location())
+	node1 := time.Date(now.Year(), now.Month(), now.Day(), 13, 0, 0, 0, Location)
+	node2 := time.Date(now.Year(), now.Month(), now.Day(), 15, 0, 0, 0, Location)
 	if ks[len(ks)-1].Time.Unix() < node1.Unix() || ks[len(ks)-1].Time.Unix() > node2.Unix() {
 		return ks
 	}
@@ -225,7 +224,7 @@ func FixKlineTime(ks []*Kline) []*Kline {
 	}
 	for i, v := range ls {
 		if v.Time.Unix() == node1.Unix() {
-			ls[i].Time = time.Date(now.Year(), now.Month(), now.Day(), 11, 30, 0, 0, now.Location())
+			ls[i].Time = time.Date(now.Year(), now.Month(), now.Day(), 11, 30, 0, 0, Location)
 		}
 	}
 	return ks
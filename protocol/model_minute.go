@@ -50,7 +50,7 @@ func (this *minute) Decode(bs []byte) (*MinuteResp, error) {
 	bs = bs[6:]
 	price := Price(0)
 
-	t := time.Date(0, 0, 0, 9, 0, 0, 0, time.Local)
+	t := time.Date(0, 0, 0, 9, 0, 0, 0, Location)
 	for i := uint16(0); i < resp.Count; i++ {
 		bs, price = GetPrice(bs)
 		bs, _ = CutInt(bs) //这个是什么
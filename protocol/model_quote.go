@@ -3,8 +3,12 @@ package protocol
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
+// QuoteMaxCodes 单次请求盘口的最大代码数量,超过服务器会不响应
+const QuoteMaxCodes = 80
+
 type QuotesResp []*Quote
 
 func (this QuotesResp) String() string {
@@ -16,18 +20,19 @@ func (this QuotesResp) String() string {
 }
 
 type Quote struct {
-	Exchange       Exchange // 市场
-	Code           string   // 股票代码 6个ascii字符串
-	Active1        uint16   // 活跃度
-	K              K        //k线
-	ServerTime     string   // 时间
-	ReversedBytes0 int      // 保留(时间 ServerTime)
-	ReversedBytes1 int      // 保留 这个等于 负的收盘价格?
-	TotalHand      int      // 总手（东财的盘口-总手）
-	Intuition      int      // 现量（东财的盘口-现量）现在成交量
-	Amount         float64  // 金额（东财的盘口-金额）
-	InsideDish     int      // 内盘（东财的盘口-外盘）（和东财对不上）
-	OuterDisc      int      // 外盘（东财的盘口-外盘）（和东财对不上）
+	Exchange       Exchange  // 市场
+	Code           string    // 股票代码 6个ascii字符串
+	Active1        uint16    // 活跃度
+	K              K         //k线
+	ServerTime     string    // 时间
+	Time           time.Time // 服务器时间,由ServerTime解析,protocol.Location时区,日期是推测的,见decodeQuoteTime
+	ReversedBytes0 int       // 保留(时间 ServerTime)
+	ReversedBytes1 int       // 负的最新价,单位分
+	TotalHand      int       // 总手（东财的盘口-总手）
+	Intuition      int       // 现量（东财的盘口-现量）现在成交量
+	Amount         float64   // 金额（东财的盘口-金额）
+	InsideDish     int       // 内盘（东财的盘口-内盘）,抓包的数据里内盘+外盘=总手,但是和东财对不上,还没有修正
+	OuterDisc      int       // 外盘（东财的盘口-外盘）,同内盘,和东财对不上
 
 	ReversedBytes2 int         // 保留，未知
 	ReversedBytes3 int         // 保留，未知,基金的昨收净值?
@@ -39,8 +44,8 @@ type Quote struct {
 	ReversedBytes6 int     // 保留，未知
 	ReversedBytes7 int     // 保留，未知
 	ReversedBytes8 int     // 保留，未知
-	ReversedBytes9 uint16  // 保留，未知
-	Rate           float64 // 涨速，好像都是0
+	ReversedBytes9 uint16  // 涨速的原始值,是有符号的
+	Rate           float64 // 涨速(%),例-0.08
 	Active2        uint16  // 活跃度
 }
 
@@ -127,6 +132,7 @@ func (this quote) Decode(bs []byte) QuotesResp {
 		bs, sec.K = DecodeK(bs[9:])
 		bs, sec.ReversedBytes0 = CutInt(bs)
 		sec.ServerTime = fmt.Sprintf("%d", sec.ReversedBytes0)
+		sec.Time = decodeQuoteTime(sec.ReversedBytes0, Now())
 		bs, sec.ReversedBytes1 = CutInt(bs)
 		bs, sec.TotalHand = CutInt(bs)
 		bs, sec.Intuition = CutInt(bs)
@@ -160,7 +166,7 @@ func (this quote) Decode(bs []byte) QuotesResp {
 		bs, sec.ReversedBytes8 = CutInt(bs)
 		sec.ReversedBytes9 = Uint16(bs[:2])
 
-		sec.Rate = float64(sec.ReversedBytes9) / 100
+		sec.Rate = float64(int16(sec.ReversedBytes9)) / 100
 		sec.Active2 = Uint16(bs[2:4])
 
 		bs = bs[4:]
@@ -170,3 +176,98 @@ func (this quote) Decode(bs []byte) QuotesResp {
 
 	return resp
 }
+
+// quoteTimeTolerance 服务器时间可以比参考时间晚的误差,超过时认为是上一个交易时段的盘口
+const quoteTimeTolerance = time.Minute * 5
+
+// decodeQuoteTime 解析盘口的服务器时间,格式是根据抓包数据推测的,没有和服务器确认过
+// 前2位是小时,后6位有2种格式:
+// 1. 2位分钟+分钟的万分之几,例15330029是15:33:00.174
+// 2. 小时的百万分之几,例14999165是14:59:56.994
+// 后6位的前2位>=60时只能是格式2,否则2种格式都有可能,取离参考时间ref(一般是当前时间)近的
+// 服务器时间没有日期,日期取ref的日期,比ref晚的(例如开盘前获取的是上一个交易日收盘的盘口)取前一个工作日,
+// 这里不知道节假日,节假日后第一个交易日开盘前的日期是不准确的
+func decodeQuoteTime(v int, ref time.Time) time.Time {
+	if v <= 0 {
+		return time.Time{}
+	}
+	ref = ref.In(Location)
+	h, rest := v/1000000, v%1000000
+	at := func(d time.Duration) time.Time {
+		t := time.Date(ref.Year(), ref.Month(), ref.Day(), h, 0, 0, 0, Location).Add(d)
+		if t.Sub(ref) > quoteTimeTolerance {
+			t = t.AddDate(0, 0, -1)
+			for t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+				t = t.AddDate(0, 0, -1)
+			}
+		}
+		return t
+	}
+
+	//格式2,小时的百万分之几,精确到微秒
+	t := at(time.Duration(rest) * time.Hour / 1000000)
+
+	//格式1,分钟+分钟的万分之几
+	if m := rest / 10000; m < 60 {
+		t1 := at(time.Duration(m)*time.Minute + time.Duration(rest%10000)*time.Minute/10000)
+		if absDuration(t1.Sub(ref)) <= absDuration(t.Sub(ref)) {
+			t = t1
+		}
+	}
+	return t
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Change 涨跌额
+func (this *Quote) Change() Price {
+	if this.K.Close <= 0 || this.K.Last <= 0 {
+		return 0
+	}
+	return this.K.Close - this.K.Last
+}
+
+// ChangeRate 涨跌幅(%),例1.23
+func (this *Quote) ChangeRate() float64 {
+	if this.K.Close <= 0 || this.K.Last <= 0 {
+		return 0
+	}
+	return float64(this.K.Close-this.K.Last) / float64(this.K.Last) * 100
+}
+
+// Amplitude 振幅(%),(最高-最低)/昨收
+func (this *Quote) Amplitude() float64 {
+	if this.K.Last <= 0 || this.K.High <= 0 || this.K.Low <= 0 {
+		return 0
+	}
+	return float64(this.K.High-this.K.Low) / float64(this.K.Last) * 100
+}
+
+// VolumeRatio 量比,当前每分钟的平均成交量/过去5日每分钟的平均成交量
+// avg5 是过去5日的日均成交量(手),按盘口的服务器时间计算已经交易的分钟数
+func (this *Quote) VolumeRatio(avg5 float64) float64 {
+	if avg5 <= 0 || this.Time.IsZero() {
+		return 0
+	}
+	n := ElapsedTradeMinutes(this.Time)
+	if n <= 0 {
+		//集合竞价期间按1分钟计算
+		n = 1
+	}
+	return float64(this.TotalHand) / float64(n) / (avg5 / 240)
+}
+
+// Spread 买卖价差,卖1-买1,没有买1或者卖1(例如涨跌停)返回0
+func (this *Quote) Spread() Price {
+	return this.OrderBook().Spread()
+}
+
+// Imbalance 5档委托的不平衡度,(买量-卖量)/(买量+卖量),范围[-1,1],正数表示买盘更多
+func (this *Quote) Imbalance() float64 {
+	return this.OrderBook().Imbalance(5)
+}
@@ -1,7 +1,9 @@
 package protocol
 
 import (
+	"encoding/hex"
 	"testing"
+	"time"
 )
 
 func Test_quote_Frame(t *testing.T) {
@@ -13,3 +15,112 @@ func Test_quote_Frame(t *testing.T) {
 	}
 	t.Log(f.Bytes().HEX())
 }
+
+func Test_quote_Decode(t *testing.T) {
+	//example/GetQuote 中抓包的数据,sz000001和sh600008
+	bs, err := hex.DecodeString("0102020000303030303031601294121a1c2d4eadabcf0ed412aae5fc01afb0024561124fbcc08301afa47900b2e3174100bf68871a4201b741b6144302bb09af334403972e96354504ac09b619560e00000000f8ff601201363030303038b60fba04060607429788a70efa04ada37ab2531c12974d91e7449dbc354184b6010001844bad324102b5679ea1014203a65abd8d0143048a6ba4dd01440587e101b3d2029613000000000000b60f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := MQuote.Decode(bs)
+	if len(ls) != 2 {
+		t.Fatalf("预期2个,得到%d", len(ls))
+	}
+
+	q := ls[0]
+	if q.ServerTime != "15330029" || q.Time.Hour() != 15 {
+		t.Errorf("服务器时间错误: %s %s", q.ServerTime, q.Time)
+	}
+	if q.Rate != -0.08 {
+		t.Errorf("涨速错误: %v", q.Rate)
+	}
+	if q.ReversedBytes1*10 != -int(q.K.Close) {
+		t.Errorf("保留字段1预期是负的最新价: %d", q.ReversedBytes1)
+	}
+	if d := q.InsideDish + q.OuterDisc - q.TotalHand; d < -1 || d > 1 {
+		t.Errorf("内盘+外盘应该等于总手: %d+%d!=%d", q.InsideDish, q.OuterDisc, q.TotalHand)
+	}
+	if r := q.ChangeRate(); r > -2.17 || r < -2.18 {
+		t.Errorf("涨跌幅错误: %v", r)
+	}
+	if r := q.Amplitude(); r > 4.93 || r < 4.92 {
+		t.Errorf("振幅错误: %v", r)
+	}
+	if s := q.Spread(); s != 10 {
+		t.Errorf("价差错误: %v", s)
+	}
+
+	if ls[1].ServerTime != "15000087" {
+		t.Errorf("服务器时间错误: %s", ls[1].ServerTime)
+	}
+}
+
+func Test_quote_DecodeFrame(t *testing.T) {
+	//Test_securityQuote_Decode 中抓包的完整响应,sz000001和sh600008
+	bs, err := hex.DecodeString("b1cb74000c02000000003e05af00af000136020000303030303031320bb2124c56105987e6d10cf212b78fa801ae01293dc54e8bd740acb8670086ca1e0001af36ba0c4102b467b6054203a68a0184094304891992114405862685108d0100000000e8ff320b0136303030303859098005464502468defd10cc005bed2668e05be15804d8ba12cb3b13a0083c3034100badc029d014201bc990384f70443029da503b7af074403a6e501b9db044504a6e2028dd5048d050000000000005909")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Decode(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := MQuote.Decode(f.Data)
+	if len(ls) != 2 {
+		t.Fatalf("预期2个,得到%d", len(ls))
+	}
+	for i, v := range []struct {
+		code       string
+		serverTime string
+		close      Price
+		rate       float64
+	}{
+		{"000001", "13252999", 12020, -0.24},
+		{"600008", "13253581", 3200, 0},
+	} {
+		q := ls[i]
+		if q.Code != v.code || q.ServerTime != v.serverTime || q.K.Close != v.close || q.Rate != v.rate {
+			t.Errorf("%s 解析错误: %s %s %v", v.code, q.ServerTime, q.K.Close, q.Rate)
+		}
+		if q.Time.Hour() != 13 {
+			t.Errorf("%s 服务器时间错误: %s", v.code, q.Time)
+		}
+		if q.ReversedBytes1*10 != -int(q.K.Close) {
+			t.Errorf("%s 保留字段1预期是负的最新价: %d", v.code, q.ReversedBytes1)
+		}
+	}
+}
+
+func Test_decodeQuoteTime(t *testing.T) {
+	date := time.Date(2024, 10, 28, 0, 0, 0, 0, Location)
+	at := func(h, m, sec, ms int) time.Time {
+		return date.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second + time.Duration(ms)*time.Millisecond)
+	}
+	for _, v := range []struct {
+		value int
+		ref   time.Time
+		want  time.Time
+	}{
+		//抓包的数据,2种格式都有可能,取离参考时间近的
+		{15330029, at(15, 33, 5, 0), at(15, 33, 0, 174)},
+		{13252999, at(13, 25, 30, 0), at(13, 25, 17, 994)},
+		{13253581, at(13, 25, 30, 0), at(13, 25, 21, 486)},
+		{15000087, at(15, 0, 1, 0), at(15, 0, 0, 522)},
+		//小时的百万分之几,0.33h=19分48秒
+		{15330000, at(15, 20, 0, 0), at(15, 19, 48, 0)},
+		//后6位的前2位>=60,只能是小时的百万分之几
+		{14999165, at(15, 0, 0, 0), at(14, 59, 56, 994)},
+		{14999165, at(14, 0, 0, 0), at(14-72, 59, 56, 994)}, //比参考时间晚1小时,是上一个交易日的
+		{9250000, at(9, 25, 0, 0), at(9, 25, 0, 0)},
+		{0, at(9, 25, 0, 0), time.Time{}},
+		//开盘前获取的是上一个交易日收盘的盘口,2024-10-28是周一,前一个工作日是周五
+		{15000087, at(24+8, 0, 0, 0), at(15, 0, 0, 522)},
+		{15000087, at(8, 0, 0, 0), at(15-72, 0, 0, 522)},
+		//服务器时间比参考时间晚一点,还是当天
+		{9300000, at(9, 28, 0, 0), at(9, 30, 0, 0)},
+	} {
+		if got := decodeQuoteTime(v.value, v.ref); !got.Equal(v.want) {
+			t.Errorf("%d 预期%s,得到%s", v.value, v.want.Format("01-02 15:04:05.000"), got.Format("01-02 15:04:05.000"))
+		}
+	}
+}
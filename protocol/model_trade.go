@@ -9,11 +9,6 @@ import (
 	"github.com/injoyai/conv"
 )
 
-var (
-	// 中国标准时间时区 (UTC+8)
-	locationCST = time.FixedZone("CST", 8*3600)
-)
-
 type TradeResp struct {
 	Count uint16
 	List  Trades
@@ -21,11 +16,13 @@ type TradeResp struct {
 
 // Trade 分时成交，todo 时间没有到秒，客户端上也没有,东方客户端能显示秒
 type Trade struct {
-	Time   time.Time //时间, 09:30
-	Price  Price     //价格
-	Volume int       //成交量,手
-	Status int       //0是买，1是卖，2中性/汇总 中途也可能出现2,例20241115(sz000001)的14:56
-	Number int       //单数,历史数据该字段无效
+	Time    time.Time //时间, 09:30
+	Price   Price     //价格
+	Volume  int       //成交量,手
+	Status  int       //0是买，1是卖，2中性/汇总 中途也可能出现2,例20241115(sz000001)的14:56
+	Number  int       //单数,历史数据该字段无效
+	Seq     int       //当天的序号,从1开始,0表示未知,获取到当天完整数据或者增量拼接(Trades.Merge)时才有效
+	Unknown int       //最后一个字段的原始值,基本是0,含义未知(可能是秒),没有验证之前不参与时间计算
 }
 
 func (this *Trade) String() string {
@@ -33,6 +30,25 @@ func (this *Trade) String() string {
 		this.Time, this.Price, this.Amount(), this.Volume, this.Number, this.StatusString())
 }
 
+// Key 去重用的唯一标识,日期+序号,没有序号(Seq=0)时返回空
+// 没有序号的成交无法唯一标识(同一秒内可能有内容完全相同的成交),去重使用Trades.Merge
+func (this *Trade) Key() string {
+	if this.Seq <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s#%d", this.Time.In(Location).Format("20060102"), this.Seq)
+}
+
+// Equal 成交内容是否一致(不比较序号),用于增量数据的重叠匹配
+func (this *Trade) Equal(t *Trade) bool {
+	return t != nil &&
+		this.Time.Equal(t.Time) &&
+		this.Price == t.Price &&
+		this.Volume == t.Volume &&
+		this.Status == t.Status &&
+		this.Number == t.Number
+}
+
 // Amount 成交额
 func (this *Trade) Amount() Price {
 	return this.Price * Price(this.Volume*100)
@@ -107,8 +123,8 @@ func (trade) Decode(bs []byte, c TradeCache) (*TradeResp, error) {
 	lastPrice := Price(0)
 	for i := uint16(0); i < resp.Count; i++ {
 		timeStr := GetHourMinute([2]byte(bs[:2]))
-		// 数据中的时间本身就是北京时间，使用CST时区解析
-		t, err := time.ParseInLocation("2006010215:04", c.Date+timeStr, locationCST)
+		// 数据中的时间本身就是北京时间，使用交易所时区解析
+		t, err := time.ParseInLocation("2006010215:04", c.Date+timeStr, Location)
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +136,7 @@ func (trade) Decode(bs []byte, c TradeCache) (*TradeResp, error) {
 		bs, mt.Volume = CutInt(bs)
 		bs, mt.Number = CutInt(bs)
 		bs, mt.Status = CutInt(bs)
-		bs, _ = CutInt(bs) //这个得到的是0，不知道是啥
+		bs, mt.Unknown = CutInt(bs) //这个得到的基本是0，不知道是啥
 		resp.List = append(resp.List, mt)
 	}
 
@@ -129,13 +145,79 @@ func (trade) Decode(bs []byte, c TradeCache) (*TradeResp, error) {
 
 type Trades []*Trade
 
+// Sequence 设置当天的序号,first是第一条数据的序号,要求数据是当天从开盘开始的完整数据
+func (this Trades) Sequence(first int) Trades {
+	for i, v := range this {
+		v.Seq = first + i
+	}
+	return this
+}
+
+// MinTradeOverlap 增量合并时最少需要重叠的成交数量,防止同一分钟内相同的成交误判
+const MinTradeOverlap = 3
+
+var (
+	ErrTradeEmpty    = errors.New("没有已有的成交数据,需要先获取当天完整的数据")
+	ErrTradeOverlap  = errors.New("没有找到足够的重叠数据,中间可能有遗漏")
+	ErrTradeTooFew   = fmt.Errorf("已有的成交数据少于%d条,无法可靠匹配", MinTradeOverlap)
+	ErrTradeNewerFew = fmt.Errorf("新数据少于%d条,无法可靠匹配", MinTradeOverlap)
+)
+
+// Merge 合并增量数据,newer是从最新开始往前获取的数据(例如GetMinuteTrade(code,0,n)),需要和已有数据的尾部重叠至少MinTradeOverlap条
+// 通过尾部重叠匹配去掉重复的数据,返回新增的数据,已有数据有序号时延续序号,否则新增数据的序号保持0
+// 已有数据为空或者太少时返回错误,需要获取当天完整的数据(例如GetMinuteTradeAll);没有找到重叠时返回ErrTradeOverlap,需要获取更多的数据再合并
+func (this Trades) Merge(newer Trades) (Trades, error) {
+	if len(this) == 0 {
+		return nil, ErrTradeEmpty
+	}
+	if len(this) < MinTradeOverlap {
+		return nil, ErrTradeTooFew
+	}
+	if len(newer) < MinTradeOverlap {
+		return nil, ErrTradeNewerFew
+	}
+
+	//用已有数据尾部的多条数据进行匹配,减少同一分钟内相同成交的误判
+	n := len(this)
+	if n > 10 {
+		n = 10
+	}
+	tail := this[len(this)-n:]
+
+	//从后往前找,取最新的匹配位置
+	for end := len(newer) - 1; end >= MinTradeOverlap-1; end-- {
+		m := n
+		if end+1 < m {
+			//newer的开头在已有数据的尾部范围内,只能部分匹配
+			m = end + 1
+		}
+		match := true
+		for i := 0; i < m; i++ {
+			if !newer[end-i].Equal(tail[len(tail)-1-i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			add := newer[end+1:]
+			if last := this[len(this)-1].Seq; last > 0 {
+				add.Sequence(last + 1)
+			}
+			return add, nil
+		}
+	}
+
+	return nil, ErrTradeOverlap
+}
+
 // Klines 合并分时成交成k线
 func (this Trades) Klines() Klines {
 	//按天分割
 	m := make(types.SortMap[int64, Trades])
 	for _, v := range this {
 		//获取当天零点的时间戳
-		unix := time.Date(v.Time.Year(), v.Time.Month(), v.Time.Day(), 0, 0, 0, 0, v.Time.Location()).Unix()
+		t := v.Time.In(Location)
+		unix := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Location).Unix()
 		m[unix] = append(m[unix], v)
 	}
 
@@ -143,7 +225,7 @@ func (this Trades) Klines() Klines {
 	mKline := types.SortMap[int64, Klines]{}
 	for date, v := range m {
 		//生成一分钟k线
-		t := time.Unix(date, 0)
+		t := time.Unix(date, 0).In(Location)
 		mKline[date] = v.klinesForDay(t)
 	}
 	//按时间排序
@@ -1,5 +1,11 @@
 package protocol
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Control uint8
 
 func (this Control) Uint8() uint8 {
@@ -46,23 +52,185 @@ const (
 	ExchangeBJ                 //北京交易所
 )
 
+// KlineType K线类型,对应协议里的类型字节
+type KlineType uint8
+
+func (this KlineType) Uint8() uint8 { return uint8(this) }
+
+// String 类型名称,可通过ParseKlineType解析回来
+func (this KlineType) String() string {
+	switch this {
+	case TypeKlineMinute:
+		return "minute"
+	case TypeKline5Minute:
+		return "5minute"
+	case TypeKline15Minute:
+		return "15minute"
+	case TypeKline30Minute:
+		return "30minute"
+	case TypeKline60Minute:
+		return "hour"
+	case TypeKlineDay2:
+		return "day2"
+	case TypeKlineMinute2:
+		return "minute2"
+	case TypeKlineDay:
+		return "day"
+	case TypeKlineWeek:
+		return "week"
+	case TypeKlineMonth:
+		return "month"
+	case TypeKlineQuarter:
+		return "quarter"
+	case TypeKlineYear:
+		return "year"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(this))
+	}
+}
+
+func (this KlineType) Name() string {
+	switch this {
+	case TypeKlineMinute, TypeKlineMinute2:
+		return "1分钟"
+	case TypeKline5Minute:
+		return "5分钟"
+	case TypeKline15Minute:
+		return "15分钟"
+	case TypeKline30Minute:
+		return "30分钟"
+	case TypeKline60Minute:
+		return "60分钟"
+	case TypeKlineDay, TypeKlineDay2:
+		return "日线"
+	case TypeKlineWeek:
+		return "周线"
+	case TypeKlineMonth:
+		return "月线"
+	case TypeKlineQuarter:
+		return "季线"
+	case TypeKlineYear:
+		return "年线"
+	default:
+		return "未知"
+	}
+}
+
+// Duration 单根K线的周期,月/季/年不固定,返回的是近似值(30/91/365天)
+func (this KlineType) Duration() time.Duration {
+	switch this {
+	case TypeKlineMinute, TypeKlineMinute2:
+		return time.Minute
+	case TypeKline5Minute:
+		return time.Minute * 5
+	case TypeKline15Minute:
+		return time.Minute * 15
+	case TypeKline30Minute:
+		return time.Minute * 30
+	case TypeKline60Minute:
+		return time.Hour
+	case TypeKlineDay, TypeKlineDay2:
+		return time.Hour * 24
+	case TypeKlineWeek:
+		return time.Hour * 24 * 7
+	case TypeKlineMonth:
+		return time.Hour * 24 * 30
+	case TypeKlineQuarter:
+		return time.Hour * 24 * 91
+	case TypeKlineYear:
+		return time.Hour * 24 * 365
+	default:
+		return 0
+	}
+}
+
+// IsMinute 是否是分钟级别(1,5,15,30,60分钟)的K线,时间格式和成交量的解析方式和日线及以上不一样
+func (this KlineType) IsMinute() bool {
+	switch this {
+	case TypeKlineMinute, TypeKlineMinute2, TypeKline5Minute, TypeKline15Minute, TypeKline30Minute, TypeKline60Minute:
+		return true
+	}
+	return false
+}
+
+// Valid 是否是有效的K线类型
+func (this KlineType) Valid() bool {
+	return this <= TypeKlineYear
+}
+
+// ParseKlineType 解析K线类型,不区分大小写,支持minute/1m/minute1/5minute/5m/minute5/hour/60m/1h/day/1d/week/month/quarter/year等写法
+func ParseKlineType(s string) (KlineType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "minute", "1minute", "minute1", "1m", "1min":
+		return TypeKlineMinute, nil
+	case "5minute", "minute5", "5m", "5min":
+		return TypeKline5Minute, nil
+	case "15minute", "minute15", "15m", "15min":
+		return TypeKline15Minute, nil
+	case "30minute", "minute30", "30m", "30min":
+		return TypeKline30Minute, nil
+	case "hour", "60minute", "minute60", "60m", "60min", "1h":
+		return TypeKline60Minute, nil
+	case "day", "1d", "d":
+		return TypeKlineDay, nil
+	case "week", "1w", "w":
+		return TypeKlineWeek, nil
+	case "month", "1mon":
+		return TypeKlineMonth, nil
+	case "quarter", "1q", "q":
+		return TypeKlineQuarter, nil
+	case "year", "1y", "y":
+		return TypeKlineYear, nil
+	case "day2":
+		return TypeKlineDay2, nil
+	case "minute2":
+		return TypeKlineMinute2, nil
+	default:
+		return 0, fmt.Errorf("未知的K线类型: %s", s)
+	}
+}
+
+const (
+	TypeKline5Minute  KlineType = 0  // 5分钟K 线
+	TypeKline15Minute KlineType = 1  // 15分钟K 线
+	TypeKline30Minute KlineType = 2  // 30分钟K 线
+	TypeKline60Minute KlineType = 3  // 60分钟K 线
+	TypeKlineDay2     KlineType = 4  // 日K 线, 发现和Day的区别是这个成交量要除以100,其他未知,推荐使用TypeKlineDay
+	TypeKlineWeek     KlineType = 5  // 周K 线
+	TypeKlineMonth    KlineType = 6  // 月K 线
+	TypeKlineMinute   KlineType = 7  // 1分钟
+	TypeKlineMinute2  KlineType = 8  // 1分钟K 线,和TypeKlineMinute未发现区别,推荐使用TypeKlineMinute
+	TypeKlineDay      KlineType = 9  // 日K 线
+	TypeKlineQuarter  KlineType = 10 // 季K 线
+	TypeKlineYear     KlineType = 11 // 年K 线
+
+	// TypeKlineHour 1小时K 线,同TypeKline60Minute
+	TypeKlineHour = TypeKline60Minute
+)
+
+// KlineTypes 常用的K线类型,不包含重复的TypeKlineDay2和TypeKlineMinute2
+var KlineTypes = []KlineType{
+	TypeKlineMinute, TypeKline5Minute, TypeKline15Minute, TypeKline30Minute, TypeKline60Minute,
+	TypeKlineDay, TypeKlineWeek, TypeKlineMonth, TypeKlineQuarter, TypeKlineYear,
+}
+
 const (
-	TypeKline5Minute  uint8 = 0  // 5分钟K 线
-	TypeKline15Minute uint8 = 1  // 15分钟K 线
-	TypeKline30Minute uint8 = 2  // 30分钟K 线
-	TypeKline60Minute uint8 = 3  // 60分钟K 线
-	TypeKlineHour     uint8 = 3  // 1小时K 线
-	TypeKlineDay2     uint8 = 4  // 日K 线, 发现和Day的区别是这个要除以100,其他未知
-	TypeKlineWeek     uint8 = 5  // 周K 线
-	TypeKlineMonth    uint8 = 6  // 月K 线
-	TypeKlineMinute   uint8 = 7  // 1分钟
-	TypeKlineMinute2  uint8 = 8  // 1分钟K 线,未知啥区别
-	TypeKlineDay      uint8 = 9  // 日K 线
-	TypeKlineQuarter  uint8 = 10 // 季K 线
-	TypeKlineYear     uint8 = 11 // 年K 线
+	KindIndex       = "index"       //指数
+	KindStock       = "stock"       //股票(A股)
+	KindBShare      = "bshare"      //B股
+	KindETF         = "etf"         //ETF基金
+	KindLOF         = "lof"         //LOF基金
+	KindBond        = "bond"        //债券(国债,企业债等)
+	KindConvertible = "convertible" //可转债
+	KindRepo        = "repo"        //国债逆回购
+	KindREIT        = "reit"        //公募REITs
+	KindOther       = "other"       //其他,未识别
 )
 
+// 股票的板块
 const (
-	KindIndex = "index"
-	KindStock = "stock"
+	BoardMain    = "main"    //主板
+	BoardChiNext = "chinext" //创业板
+	BoardSTAR    = "star"    //科创板
+	BoardBSE     = "bse"     //北交所
 )
@@ -0,0 +1,242 @@
+package protocol
+
+import (
+	"time"
+)
+
+// 涨跌幅限制规则变化的日期,规则从这天开始生效
+var (
+	limitStart       = time.Date(1996, 12, 16, 0, 0, 0, 0, Location) //沪深开始实行10%的涨跌幅限制
+	limitIPOFirstDay = time.Date(2014, 1, 1, 0, 0, 0, 0, Location)   //新股上市首日涨幅限制44%,跌幅限制36%
+	limitChiNext     = time.Date(2020, 8, 24, 0, 0, 0, 0, Location)  //创业板注册制,10%改成20%(包括ST),新股前5日没有涨跌幅限制
+	limitConvertible = time.Date(2022, 8, 1, 0, 0, 0, 0, Location)   //可转债开始有20%的涨跌幅限制,之前没有
+	limitMain        = time.Date(2023, 4, 10, 0, 0, 0, 0, Location)  //主板注册制,新股前5日没有涨跌幅限制
+	limitMainST      = time.Date(2025, 7, 7, 0, 0, 0, 0, Location)   //主板ST从5%改成10%
+)
+
+// LimitRule 涨跌幅限制的规则,根据代码(板块),名称(ST)和上市日期确定涨跌幅比例
+type LimitRule struct {
+	Code     string    //带交易所前缀的代码,例sz000001
+	Name     string    //名称,用于识别ST和新股(N开头是上市首日,C开头是注册制新股上市第2-5日)
+	ListDate time.Time //上市日期,零值表示未知
+}
+
+// Percent 在date这天的涨幅限制的百分比,0表示没有涨跌幅限制,date为零值时按现在的规则
+// tradingDay 是上市后的第几个交易日,1是上市首日,<=0表示未知(按名称识别新股)
+// 注册制之前的新股上市首日涨幅是44%,跌幅是36%,跌幅见Limit
+func (this LimitRule) Percent(date time.Time, tradingDay int) int64 {
+	up, _ := this.percent(date, tradingDay)
+	return up
+}
+
+// percent 在date这天的涨幅和跌幅限制的百分比
+func (this LimitRule) percent(date time.Time, tradingDay int) (int64, int64) {
+	if date.IsZero() {
+		date = Now()
+	}
+	after := func(t time.Time) bool { return !date.Before(t) }
+	code := this.Code
+
+	switch CodeKind(code) {
+	case KindStock:
+		if !after(limitStart) {
+			return 0, 0
+		}
+		if IsBJStock(code) {
+			//北交所从2021-11-15开市就是30%,只有上市首日没有涨跌幅限制
+			if tradingDay == 1 || (tradingDay <= 0 && this.newListing('N')) {
+				return 0, 0
+			}
+			return 30, 30
+		}
+
+		//新股前5日没有涨跌幅限制,从各板块实行注册制开始,科创板从2019-07-22开市就是注册制
+		registered := IsSTAR(code) || (IsChiNext(code) && after(limitChiNext)) || after(limitMain)
+		switch {
+		case registered && tradingDay > 0 && tradingDay <= 5:
+			return 0, 0
+		case registered && tradingDay <= 0 && this.newListing('N', 'C'):
+			return 0, 0
+		case !registered && (tradingDay == 1 || (tradingDay <= 0 && this.newListing('N'))):
+			//核准制的新股上市首日,2014年之前没有涨跌幅限制
+			if after(limitIPOFirstDay) {
+				return 44, 36
+			}
+			return 0, 0
+		}
+
+		switch {
+		case IsSTAR(code), IsChiNext(code) && after(limitChiNext):
+			return 20, 20
+		case IsST(this.Name) && !after(limitMainST):
+			//主板ST(包括注册制之前的创业板ST)是5%
+			return 5, 5
+		default:
+			return 10, 10
+		}
+
+	case KindBShare, KindETF, KindLOF, KindREIT:
+		if !after(limitStart) {
+			return 0, 0
+		}
+		return 10, 10
+
+	case KindConvertible:
+		//上市首日是按发行价的57.3%和43.3%,不是整数百分比,这里按没有涨跌幅限制
+		if !after(limitConvertible) || tradingDay == 1 {
+			return 0, 0
+		}
+		return 20, 20
+
+	}
+	//指数,债券等没有涨跌幅限制
+	return 0, 0
+}
+
+// newListing 通过名称判断是否是新股,prefix是新股名称的前缀,N开头是上市首日,C开头是注册制新股上市第2-5日
+func (this LimitRule) newListing(prefix ...byte) bool {
+	if len(this.Name) < 2 || this.Name[1] < 0x80 {
+		//例如TCL科技,不是新股的标识
+		return false
+	}
+	for _, v := range prefix {
+		if this.Name[0] == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Tick 最小价格变动单位,股票是0.01元,基金债券等是0.001元
+func (this LimitRule) Tick() Price {
+	if CodeKind(this.Code) == KindStock {
+		return 10
+	}
+	return 1
+}
+
+// Limit 根据昨收价(新股上市首日是发行价)计算date这天的涨跌停价,参数同Percent
+func (this LimitRule) Limit(last Price, date time.Time, tradingDay int) PriceLimit {
+	up, down := this.percent(date, tradingDay)
+	return newPriceLimit(last, up, down, this.Tick())
+}
+
+// NewPriceLimit 计算涨跌停价,昨收价*(1±percent%),按最小价格变动单位四舍五入
+func NewPriceLimit(last Price, percent int64, tick Price) PriceLimit {
+	return newPriceLimit(last, percent, percent, tick)
+}
+
+// newPriceLimit 涨幅和跌幅限制不同的涨跌停价,例如核准制新股上市首日是44%和36%
+func newPriceLimit(last Price, percent, downPercent int64, tick Price) PriceLimit {
+	if last <= 0 || percent <= 0 || tick <= 0 {
+		return PriceLimit{Last: last}
+	}
+	//放大100倍用整数计算,避免浮点误差,例11.98*1.1=13.178,四舍五入是13.18
+	round := func(v int64) Price {
+		unit := int64(tick) * 100
+		return Price((v+unit/2)/unit) * tick
+	}
+	p := PriceLimit{
+		Last:    last,
+		Percent: percent,
+		Up:      round(int64(last) * (100 + percent)),
+		Down:    round(int64(last) * (100 - downPercent)),
+	}
+	if p.Down < tick {
+		p.Down = tick
+	}
+	return p
+}
+
+// PriceLimit 涨跌停价
+type PriceLimit struct {
+	Last    Price //昨收价
+	Percent int64 //涨幅限制的百分比,0表示没有涨跌幅限制,跌幅一般相同
+	Up      Price //涨停价
+	Down    Price //跌停价
+}
+
+// Limited 是否有涨跌幅限制
+func (this PriceLimit) Limited() bool {
+	return this.Percent > 0 && this.Up > 0
+}
+
+// LimitFlag 涨跌停状态,可以组合
+type LimitFlag uint8
+
+const (
+	LimitUp     LimitFlag = 1 << iota //最新价(收盘价)是涨停价
+	LimitDown                         //最新价(收盘价)是跌停价
+	LimitSealed                       //封板,盘口是涨停没有卖盘(跌停没有买盘),k线是收盘在涨跌停价
+	LimitOpened                       //开板,盘中到过涨跌停价,但是最新价(收盘价)不在涨跌停价
+)
+
+func (this LimitFlag) Has(f LimitFlag) bool {
+	return this&f != 0
+}
+
+func (this LimitFlag) String() string {
+	s := ""
+	for _, v := range []struct {
+		f    LimitFlag
+		name string
+	}{
+		{LimitUp, "涨停"},
+		{LimitDown, "跌停"},
+		{LimitSealed, "封板"},
+		{LimitOpened, "开板"},
+	} {
+		if this.Has(v.f) {
+			s += v.name
+		}
+	}
+	return s
+}
+
+// K 判断k线(日线)的涨跌停状态,昨收价使用PriceLimit.Last
+// 涨停开板是最高价到过涨停价但收盘不在涨停价,跌停同理,同时到过涨停和跌停的以收盘价为准
+func (this PriceLimit) K(k K) LimitFlag {
+	if !this.Limited() {
+		return 0
+	}
+	switch {
+	case k.Close == this.Up:
+		return LimitUp | LimitSealed
+	case k.Close == this.Down:
+		return LimitDown | LimitSealed
+	case k.High >= this.Up:
+		return LimitUp | LimitOpened
+	case k.Low > 0 && k.Low <= this.Down:
+		return LimitDown | LimitOpened
+	}
+	return 0
+}
+
+// Kline 判断k线(日线)的涨跌停状态
+func (this PriceLimit) Kline(k *Kline) LimitFlag {
+	return this.K(K{Last: k.Last, Open: k.Open, High: k.High, Low: k.Low, Close: k.Close})
+}
+
+// Quote 判断盘口的涨跌停状态,涨停时卖1没有委托才算封板,跌停时买1没有委托才算封板
+func (this PriceLimit) Quote(q *Quote) LimitFlag {
+	if !this.Limited() {
+		return 0
+	}
+	switch {
+	case q.K.Close == this.Up:
+		if q.SellLevel[0].Number == 0 {
+			return LimitUp | LimitSealed
+		}
+		return LimitUp
+	case q.K.Close == this.Down:
+		if q.BuyLevel[0].Number == 0 {
+			return LimitDown | LimitSealed
+		}
+		return LimitDown
+	case q.K.High >= this.Up:
+		return LimitUp | LimitOpened
+	case q.K.Low > 0 && q.K.Low <= this.Down:
+		return LimitDown | LimitOpened
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitRule_Percent(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, Location)
+	}
+	now := day(2025, 10, 20)
+	for _, v := range []struct {
+		rule LimitRule
+		date time.Time
+		day  int
+		want int64
+	}{
+		{LimitRule{Code: "sz000001", Name: "平安银行"}, now, 0, 10},
+		{LimitRule{Code: "sh600000", Name: "*ST浦发"}, now, 0, 10},
+		{LimitRule{Code: "sz300750", Name: "ST宁德"}, now, 0, 20},
+		{LimitRule{Code: "sh688981", Name: "中芯国际"}, now, 0, 20},
+		{LimitRule{Code: "bj920001", Name: "纬达光电"}, now, 0, 30},
+		{LimitRule{Code: "sz000001", Name: "平安银行"}, now, 1, 0},
+		{LimitRule{Code: "sz000001", Name: "平安银行"}, now, 5, 0},
+		{LimitRule{Code: "sz000001", Name: "平安银行"}, now, 6, 10},
+		{LimitRule{Code: "sz300750", Name: "宁德时代"}, now, 5, 0},
+		{LimitRule{Code: "sz300750", Name: "宁德时代"}, now, 6, 20},
+		{LimitRule{Code: "sh603001", Name: "N新股"}, now, 0, 0},
+		{LimitRule{Code: "sh603001", Name: "C新股"}, now, 0, 0},
+		{LimitRule{Code: "sz301001", Name: "C新股"}, now, 0, 0},
+		{LimitRule{Code: "sz000100", Name: "TCL科技"}, now, 0, 10},
+		{LimitRule{Code: "sz159558"}, now, 0, 10},
+		{LimitRule{Code: "sh113050"}, now, 0, 20},
+		{LimitRule{Code: "sh000001"}, now, 0, 0},
+
+		//沪深开始实行涨跌幅限制
+		{LimitRule{Code: "sz000001", Name: "深发展A"}, day(1996, 12, 13), 0, 0},
+		{LimitRule{Code: "sz000001", Name: "深发展A"}, day(1996, 12, 16), 0, 10},
+		//创业板注册制
+		{LimitRule{Code: "sz300750", Name: "宁德时代"}, day(2020, 8, 21), 0, 10},
+		{LimitRule{Code: "sz300750", Name: "宁德时代"}, day(2020, 8, 24), 0, 20},
+		{LimitRule{Code: "sz300001", Name: "ST特锐"}, day(2020, 8, 21), 0, 5},
+		{LimitRule{Code: "sz300001", Name: "ST特锐"}, day(2020, 8, 24), 0, 20},
+		{LimitRule{Code: "sz300001", Name: "特锐德"}, day(2020, 8, 21), 3, 10},
+		{LimitRule{Code: "sz300001", Name: "特锐德"}, day(2020, 8, 24), 3, 0},
+		//主板注册制,之前的新股上市首日是44%,第2天开始是10%
+		{LimitRule{Code: "sh603001", Name: "N新股"}, day(2023, 4, 7), 0, 44},
+		{LimitRule{Code: "sh603001", Name: "C新股"}, day(2023, 4, 7), 0, 10},
+		{LimitRule{Code: "sh603001", Name: "新股"}, day(2023, 4, 7), 1, 44},
+		{LimitRule{Code: "sh603001", Name: "新股"}, day(2023, 4, 7), 2, 10},
+		{LimitRule{Code: "sh603001", Name: "新股"}, day(2023, 4, 10), 1, 0},
+		{LimitRule{Code: "sh603001", Name: "新股"}, day(2023, 4, 10), 2, 0},
+		{LimitRule{Code: "sh603001", Name: "N新股"}, day(2013, 12, 31), 0, 0},
+		//科创板开市就是注册制
+		{LimitRule{Code: "sh688001", Name: "华兴源创"}, day(2019, 7, 22), 1, 0},
+		{LimitRule{Code: "sh688001", Name: "华兴源创"}, day(2019, 7, 29), 6, 20},
+		//北交所只有上市首日没有涨跌幅限制
+		{LimitRule{Code: "bj920001", Name: "N纬达"}, now, 0, 0},
+		{LimitRule{Code: "bj920001", Name: "纬达光电"}, now, 1, 0},
+		{LimitRule{Code: "bj920001", Name: "纬达光电"}, now, 2, 30},
+		//可转债
+		{LimitRule{Code: "sh113050"}, day(2022, 7, 29), 0, 0},
+		{LimitRule{Code: "sh113050"}, day(2022, 8, 1), 0, 20},
+		{LimitRule{Code: "sh113050"}, day(2022, 8, 1), 1, 0},
+		//主板ST从5%改成10%
+		{LimitRule{Code: "sh600000", Name: "*ST浦发"}, day(2025, 7, 4), 0, 5},
+		{LimitRule{Code: "sh600000", Name: "*ST浦发"}, day(2025, 7, 7), 0, 10},
+	} {
+		if got := v.rule.Percent(v.date, v.day); got != v.want {
+			t.Errorf("%s(%s) %s 第%d天 预期%d,得到%d", v.rule.Code, v.rule.Name, v.date.Format("20060102"), v.day, v.want, got)
+		}
+	}
+
+	//核准制的新股上市首日,涨幅44%,跌幅36%
+	p := LimitRule{Code: "sh603001", Name: "N新股"}.Limit(1000, day(2023, 4, 7), 0)
+	if p.Up != 1440 || p.Down != 640 {
+		t.Errorf("预期1440/640,得到%d/%d", p.Up, p.Down)
+	}
+}
+
+func TestNewPriceLimit(t *testing.T) {
+	for _, v := range []struct {
+		last, up, down Price
+		percent        int64
+		tick           Price
+	}{
+		{11980, 13180, 10780, 10, 10}, //13.178->13.18 10.782->10.78
+		{3200, 3520, 2880, 10, 10},
+		{2450, 2570, 2330, 5, 10},    //2.5725->2.57 2.3275->2.33
+		{12345, 14810, 9880, 20, 10}, //14.814->14.81 9.876->9.88
+		{10000, 13000, 7000, 30, 10},
+		{1234, 1357, 1111, 10, 1}, //基金3位小数 1.3574->1.357 1.1106->1.111
+	} {
+		p := NewPriceLimit(v.last, v.percent, v.tick)
+		if p.Up != v.up || p.Down != v.down {
+			t.Errorf("昨收%d 预期%d/%d,得到%d/%d", v.last, v.up, v.down, p.Up, p.Down)
+		}
+	}
+}
+
+func TestPriceLimit_Flag(t *testing.T) {
+	p := NewPriceLimit(10000, 10, 10)
+	if f := p.K(K{High: 11000, Low: 10500, Close: 11000}); f != LimitUp|LimitSealed {
+		t.Errorf("预期涨停封板,得到%s", f)
+	}
+	if f := p.K(K{High: 11000, Low: 10500, Close: 10800}); f != LimitUp|LimitOpened {
+		t.Errorf("预期涨停开板,得到%s", f)
+	}
+	if f := p.K(K{High: 10000, Low: 9000, Close: 9000}); f != LimitDown|LimitSealed {
+		t.Errorf("预期跌停封板,得到%s", f)
+	}
+	if f := p.K(K{High: 10500, Low: 9500, Close: 10000}); f != 0 {
+		t.Errorf("预期没有涨跌停,得到%s", f)
+	}
+
+	q := &Quote{K: K{High: 11000, Low: 10500, Close: 11000}}
+	q.BuyLevel[0] = PriceLevel{Buy: true, Price: 11000, Number: 5000}
+	if f := p.Quote(q); f != LimitUp|LimitSealed {
+		t.Errorf("预期涨停封板,得到%s", f)
+	}
+	q.SellLevel[0] = PriceLevel{Price: 11000, Number: 100}
+	if f := p.Quote(q); f != LimitUp {
+		t.Errorf("预期涨停未封板,得到%s", f)
+	}
+}
@@ -0,0 +1,230 @@
+package protocol
+
+import (
+	"sort"
+	"time"
+)
+
+// OrderBook 5档盘口,用于计算盘口的各项指标
+type OrderBook struct {
+	Time time.Time   //盘口的时间
+	Buy  PriceLevels //买1-5
+	Sell PriceLevels //卖1-5
+}
+
+// OrderBook 获取盘口
+func (this *Quote) OrderBook() OrderBook {
+	return OrderBook{
+		Time: this.Time,
+		Buy:  this.BuyLevel,
+		Sell: this.SellLevel,
+	}
+}
+
+// Cumulative 从1档开始的累计委托量
+func (this PriceLevels) Cumulative() [5]int {
+	ls := [5]int{}
+	n := 0
+	for i, v := range this {
+		n += v.Number
+		ls[i] = n
+	}
+	return ls
+}
+
+// VWAP 前n档的委托均价,没有委托返回0
+func (this PriceLevels) VWAP(n int) Price {
+	amount, volume := 0.0, 0
+	for i := 0; i < n && i < len(this); i++ {
+		if this[i].Price <= 0 || this[i].Number <= 0 {
+			continue
+		}
+		amount += float64(this[i].Price) * float64(this[i].Number)
+		volume += this[i].Number
+	}
+	if volume == 0 {
+		return 0
+	}
+	return Price(amount / float64(volume))
+}
+
+// valid 1档是否有委托
+func (this PriceLevels) valid() bool {
+	return this[0].Price > 0 && this[0].Number > 0
+}
+
+// Mid 中间价,(买1+卖1)/2,有一边没有委托返回0
+func (this OrderBook) Mid() Price {
+	if !this.Buy.valid() || !this.Sell.valid() {
+		return 0
+	}
+	return (this.Buy[0].Price + this.Sell[0].Price) / 2
+}
+
+// Spread 买卖价差,卖1-买1,有一边没有委托返回0
+func (this OrderBook) Spread() Price {
+	if !this.Buy.valid() || !this.Sell.valid() {
+		return 0
+	}
+	return this.Sell[0].Price - this.Buy[0].Price
+}
+
+// WeightedMid 加权中间价,前n档买盘均价和卖盘均价的平均值
+func (this OrderBook) WeightedMid(n int) Price {
+	buy, sell := this.Buy.VWAP(n), this.Sell.VWAP(n)
+	if buy == 0 || sell == 0 {
+		return 0
+	}
+	return (buy + sell) / 2
+}
+
+// MicroPrice 微观价格,按1档委托量加权,买盘越多越接近卖1
+// (卖1价*买1量+买1价*卖1量)/(买1量+卖1量)
+func (this OrderBook) MicroPrice() Price {
+	if !this.Buy.valid() || !this.Sell.valid() {
+		return 0
+	}
+	b, s := float64(this.Buy[0].Number), float64(this.Sell[0].Number)
+	return Price((float64(this.Sell[0].Price)*b + float64(this.Buy[0].Price)*s) / (b + s))
+}
+
+// Depth 距离1档价格ticks个最小变动价位(tick,例如股票是10厘)以内的委托量
+func (this OrderBook) Depth(ticks int, tick Price) (buy, sell int) {
+	if this.Buy.valid() {
+		limit := this.Buy[0].Price - Price(ticks)*tick
+		for _, v := range this.Buy {
+			if v.Price > 0 && v.Price >= limit {
+				buy += v.Number
+			}
+		}
+	}
+	if this.Sell.valid() {
+		limit := this.Sell[0].Price + Price(ticks)*tick
+		for _, v := range this.Sell {
+			if v.Price > 0 && v.Price <= limit {
+				sell += v.Number
+			}
+		}
+	}
+	return
+}
+
+// Imbalance 前n档委托的不平衡度,(买量-卖量)/(买量+卖量),范围[-1,1],正数表示买盘更多
+func (this OrderBook) Imbalance(n int) float64 {
+	if n <= 0 || n > 5 {
+		n = 5
+	}
+	buy, sell := this.Buy.Cumulative()[n-1], this.Sell.Cumulative()[n-1]
+	if buy+sell == 0 {
+		return 0
+	}
+	return float64(buy-sell) / float64(buy+sell)
+}
+
+// OrderBookPoint 盘口指标的一个快照
+type OrderBookPoint struct {
+	Time       time.Time
+	Mid        Price   //中间价
+	MicroPrice Price   //微观价格
+	Spread     Price   //价差
+	Imbalance  float64 //5档不平衡度
+	BuyVolume  int     //5档买盘委托量
+	SellVolume int     //5档卖盘委托量
+}
+
+// OrderBookSeries 连续的盘口指标序列,例如每次轮询盘口时添加
+type OrderBookSeries struct {
+	Max  int //最多保留的数量,<=0不限制
+	List []OrderBookPoint
+}
+
+// Add 添加盘口快照,返回这次的指标
+func (this *OrderBookSeries) Add(b OrderBook) OrderBookPoint {
+	p := OrderBookPoint{
+		Time:       b.Time,
+		Mid:        b.Mid(),
+		MicroPrice: b.MicroPrice(),
+		Spread:     b.Spread(),
+		Imbalance:  b.Imbalance(5),
+		BuyVolume:  b.Buy.Volume(),
+		SellVolume: b.Sell.Volume(),
+	}
+	this.List = append(this.List, p)
+	if this.Max > 0 && len(this.List) > this.Max {
+		this.List = this.List[len(this.List)-this.Max:]
+	}
+	return p
+}
+
+// Imbalances 不平衡度序列
+func (this *OrderBookSeries) Imbalances() []float64 {
+	ls := make([]float64, len(this.List))
+	for i, v := range this.List {
+		ls[i] = v.Imbalance
+	}
+	return ls
+}
+
+// LargeOrder 大单的变化
+type LargeOrder struct {
+	Buy    bool  //是否是买盘
+	Price  Price //价格
+	Number int   //委托量的变化(手),正数是出现,负数是消失(撤单或者成交)
+}
+
+// Appear 是否是新出现的大单
+func (this LargeOrder) Appear() bool {
+	return this.Number > 0
+}
+
+// DetectLargeOrders 对比2次盘口,找出委托量变化>=threshold(手)的价位
+// 只对比2次盘口都能看到的价格范围,价格移出5档之外不算消失
+func DetectLargeOrders(last, now OrderBook, threshold int) []LargeOrder {
+	if threshold <= 0 {
+		return nil
+	}
+	ls := detectLargeOrders(true, last.Buy, now.Buy, threshold)
+	return append(ls, detectLargeOrders(false, last.Sell, now.Sell, threshold)...)
+}
+
+func detectLargeOrders(buy bool, last, now PriceLevels, threshold int) []LargeOrder {
+	//5档都有委托时,最后一档之外的价格看不到,买盘看不到更低的,卖盘看不到更高的
+	visible := func(p Price) bool {
+		for _, ls := range []PriceLevels{last, now} {
+			if ls[4].Price <= 0 || ls[4].Number <= 0 {
+				continue
+			}
+			if (buy && p < ls[4].Price) || (!buy && p > ls[4].Price) {
+				return false
+			}
+		}
+		return true
+	}
+
+	m := map[Price]int{}
+	for _, v := range last {
+		if v.Price > 0 && visible(v.Price) {
+			m[v.Price] -= v.Number
+		}
+	}
+	for _, v := range now {
+		if v.Price > 0 && visible(v.Price) {
+			m[v.Price] += v.Number
+		}
+	}
+
+	result := []LargeOrder(nil)
+	for p, n := range m {
+		if n >= threshold || -n >= threshold {
+			result = append(result, LargeOrder{Buy: buy, Price: p, Number: n})
+		}
+	}
+	//从1档开始排序
+	sort.Slice(result, func(i, j int) bool {
+		if buy {
+			return result[i].Price > result[j].Price
+		}
+		return result[i].Price < result[j].Price
+	})
+	return result
+}
@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"testing"
+)
+
+func testOrderBook(buy, sell [5][2]int) OrderBook {
+	b := OrderBook{}
+	for i := range buy {
+		b.Buy[i] = PriceLevel{Buy: true, Price: Price(buy[i][0]), Number: buy[i][1]}
+		b.Sell[i] = PriceLevel{Price: Price(sell[i][0]), Number: sell[i][1]}
+	}
+	return b
+}
+
+func TestOrderBook(t *testing.T) {
+	b := testOrderBook(
+		[5][2]int{{10000, 300}, {9990, 100}, {9980, 100}, {9970, 100}, {9960, 100}},
+		[5][2]int{{10010, 100}, {10020, 100}, {10030, 100}, {10040, 100}, {10050, 100}},
+	)
+	if p := b.Mid(); p != 10005 {
+		t.Errorf("中间价错误: %d", p)
+	}
+	if p := b.Spread(); p != 10 {
+		t.Errorf("价差错误: %d", p)
+	}
+	//(10010*300+10000*100)/400
+	if p := b.MicroPrice(); p != 10007 {
+		t.Errorf("微观价格错误: %d", p)
+	}
+	if buy, sell := b.Depth(2, 10); buy != 500 || sell != 300 {
+		t.Errorf("深度错误: %d %d", buy, sell)
+	}
+	if c := b.Buy.Cumulative(); c[4] != 700 || c[0] != 300 {
+		t.Errorf("累计委托量错误: %v", c)
+	}
+	if r := b.Imbalance(1); r != 0.5 {
+		t.Errorf("1档不平衡度错误: %v", r)
+	}
+
+	s := &OrderBookSeries{Max: 2}
+	s.Add(b)
+	s.Add(b)
+	s.Add(OrderBook{})
+	if ls := s.Imbalances(); len(ls) != 2 || ls[0] != b.Imbalance(5) || ls[1] != 0 {
+		t.Errorf("不平衡度序列错误: %v", ls)
+	}
+}
+
+func TestDetectLargeOrders(t *testing.T) {
+	last := testOrderBook(
+		[5][2]int{{10000, 300}, {9990, 100}, {9980, 100}, {9970, 100}, {9960, 100}},
+		[5][2]int{{10010, 100}, {10020, 100}, {10030, 5000}, {10040, 100}, {10050, 100}},
+	)
+	//买1被吃掉,9990出现大单,卖3的大单撤掉,价格下移导致9950进入5档,10050移出5档
+	now := testOrderBook(
+		[5][2]int{{9990, 3100}, {9980, 100}, {9970, 100}, {9960, 100}, {9950, 2000}},
+		[5][2]int{{10000, 100}, {10010, 100}, {10020, 100}, {10030, 100}, {10040, 100}},
+	)
+	ls := DetectLargeOrders(last, now, 1000)
+	if len(ls) != 2 {
+		t.Fatalf("预期2个大单变化,得到%v", ls)
+	}
+	if !ls[0].Buy || ls[0].Price != 9990 || ls[0].Number != 3000 || !ls[0].Appear() {
+		t.Errorf("买盘大单错误: %+v", ls[0])
+	}
+	if ls[1].Buy || ls[1].Price != 10030 || ls[1].Number != -4900 || ls[1].Appear() {
+		t.Errorf("卖盘大单错误: %+v", ls[1])
+	}
+}
@@ -42,6 +42,15 @@ func (this PriceLevels) String() string {
 	return s
 }
 
+// Volume 委托量合计
+func (this PriceLevels) Volume() int {
+	n := 0
+	for _, v := range this {
+		n += v.Number
+	}
+	return n
+}
+
 // K k线图
 type K struct {
 	Last  Price //昨天收盘价
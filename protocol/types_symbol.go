@@ -0,0 +1,115 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Symbol 证券代码
+type Symbol struct {
+	Exchange Exchange //交易所
+	Code     string   //6位代码,例000001
+	Type     string   //品种,KindStock,KindIndex等
+}
+
+// String 带交易所前缀的代码,例sz000001,客户端的方法都可以直接使用
+func (this Symbol) String() string {
+	return this.Exchange.String() + this.Code
+}
+
+// ParseSymbol 解析各种格式的证券代码,支持:
+//
+//	000001    按代码规则识别交易所,例000001是平安银行,不是上证指数
+//	sz000001  SZ000001
+//	000001.SZ 600000.SH 600000.SS
+//	sh.600000 SH:600000
+//	1.600000  东方财富的格式,1是上海,0是深圳(北交所也是0)
+func ParseSymbol(s string) (Symbol, error) {
+	raw := s
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	var exchange string
+	switch {
+	case len(s) == 8 && s[1] == '.' && isDigits(s[2:]):
+		//1.600000 0.000001
+		switch s[0] {
+		case '1':
+			exchange = ExchangeSH.String()
+		case '0':
+			exchange = ExchangeSZ.String()
+			if e, ok := guessExchange(s[2:]); ok && e == ExchangeBJ {
+				exchange = ExchangeBJ.String()
+			}
+		}
+		s = s[2:]
+
+	case len(s) == 8 && isDigits(s[2:]):
+		//sz000001
+		exchange, s = s[:2], s[2:]
+
+	case len(s) == 9 && (s[2] == '.' || s[2] == ':') && isDigits(s[3:]):
+		//sh.600000 sh:600000
+		exchange, s = s[:2], s[3:]
+
+	case len(s) == 9 && s[6] == '.' && isDigits(s[:6]):
+		//000001.sz 600000.ss
+		exchange, s = s[7:], s[:6]
+		if exchange == "ss" {
+			exchange = ExchangeSH.String()
+		}
+
+	case len(s) == 6 && isDigits(s):
+		e, ok := guessExchange(s)
+		if !ok {
+			return Symbol{}, fmt.Errorf("无法识别代码[%s]的交易所,请带上交易所前缀,例如:sz000001", raw)
+		}
+		exchange = e.String()
+
+	default:
+		return Symbol{}, fmt.Errorf("代码[%s]格式错误,例如:sz000001,000001.SZ", raw)
+	}
+
+	sym := Symbol{Code: s}
+	switch exchange {
+	case ExchangeSH.String():
+		sym.Exchange = ExchangeSH
+	case ExchangeSZ.String():
+		sym.Exchange = ExchangeSZ
+	case ExchangeBJ.String():
+		sym.Exchange = ExchangeBJ
+	default:
+		return Symbol{}, fmt.Errorf("代码[%s]的交易所错误,例如:sz000001", raw)
+	}
+	sym.Type = CodeKind(sym.String())
+	return sym, nil
+}
+
+// guessExchange 根据6位代码的规则识别交易所,指数和股票冲突的(例000001),按股票处理
+func guessExchange(code string) (Exchange, bool) {
+	switch {
+	case code[:3] == "880" || code[:3] == "881":
+		//通达信的板块指数
+		return ExchangeSH, true
+	case code[:1] == "6", code[:1] == "5", code[:1] == "9" && code[:2] != "92",
+		code[:2] == "01", code[:2] == "02", code[:2] == "11", code[:3] == "204":
+		//上海股票,科创板,基金,REITs,B股,国债(010,019),贴现国债(020),可转债,逆回购
+		return ExchangeSH, true
+	case code[:2] == "00", code[:2] == "30", code[:2] == "39", code[:2] == "12", code[:2] == "13",
+		code[:2] == "15", code[:2] == "16", code[:2] == "18", code[:3] == "200":
+		//深圳股票,创业板,指数,可转债,逆回购,基金,REITs,B股
+		return ExchangeSZ, true
+	case code[:1] == "8", code[:2] == "92", code[:2] == "43":
+		//北京股票
+		return ExchangeBJ, true
+	}
+	return 0, false
+}
+
+func isDigits(s string) bool {
+	for _, v := range s {
+		if v < '0' || v > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
@@ -0,0 +1,51 @@
+package protocol
+
+import (
+	"testing"
+)
+
+func TestParseSymbol(t *testing.T) {
+	for s, want := range map[string]string{
+		"000001":    "sz000001",
+		"sz000001":  "sz000001",
+		"SZ000001":  "sz000001",
+		"000001.SZ": "sz000001",
+		"600000.SS": "sh600000",
+		"1.600000":  "sh600000",
+		"0.000001":  "sz000001",
+		"0.830799":  "bj830799",
+		"SH:600000": "sh600000",
+		"sh.600000": "sh600000",
+		" 688981 ":  "sh688981",
+		"501018":    "sh501018",
+		"508000":    "sh508000",
+		"113050":    "sh113050",
+		"123107":    "sz123107",
+		"399001":    "sz399001",
+		"300750":    "sz300750",
+		"010107":    "sh010107",
+		"019547":    "sh019547",
+		"020001":    "sh020001",
+		"920001":    "bj920001",
+		"sh000001":  "sh000001",
+	} {
+		sym, err := ParseSymbol(s)
+		if err != nil {
+			t.Errorf("[%s] %v", s, err)
+			continue
+		}
+		if sym.String() != want {
+			t.Errorf("[%s]预期%s,得到%s", s, want, sym)
+		}
+	}
+
+	if sym, _ := ParseSymbol("sh000001"); sym.Type != KindIndex || sym.Exchange != ExchangeSH || sym.Code != "000001" {
+		t.Errorf("解析错误: %+v", sym)
+	}
+
+	for _, s := range []string{"", "00001", "xx000001", "000001.XX", "2.600000", "abcdef", "700001"} {
+		if _, err := ParseSymbol(s); err == nil {
+			t.Errorf("[%s]预期错误", s)
+		}
+	}
+}
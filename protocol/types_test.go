@@ -0,0 +1,49 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseKlineType(t *testing.T) {
+	for _, v := range KlineTypes {
+		Type, err := ParseKlineType(v.String())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if Type != v {
+			t.Errorf("解析错误,预期%s,得到%s", v, Type)
+		}
+	}
+
+	for s, v := range map[string]KlineType{
+		"minute1":  TypeKlineMinute,
+		"5m":       TypeKline5Minute,
+		"HOUR":     TypeKline60Minute,
+		"60minute": TypeKlineHour,
+		"1d":       TypeKlineDay,
+	} {
+		Type, err := ParseKlineType(s)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if Type != v {
+			t.Errorf("解析[%s]错误,预期%s,得到%s", s, v, Type)
+		}
+	}
+
+	if _, err := ParseKlineType("abc"); err == nil {
+		t.Error("预期解析失败")
+	}
+}
+
+func TestKlineType_Duration(t *testing.T) {
+	if TypeKline15Minute.Duration() != time.Minute*15 {
+		t.Error("15分钟周期错误")
+	}
+	if !TypeKline60Minute.IsMinute() || TypeKlineDay.IsMinute() {
+		t.Error("分钟类型判断错误")
+	}
+}
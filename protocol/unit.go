@@ -48,21 +48,13 @@ func UTF8ToGBK(text []byte) []byte {
 	return bytes.ReplaceAll(content, []byte{0x00}, []byte{})
 }
 
+// DecodeCode 解析代码的交易所和6位代码,支持的格式见ParseSymbol
 func DecodeCode(code string) (Exchange, string, error) {
-	code = AddPrefix(code)
-	if len(code) != 8 {
-		return 0, "", fmt.Errorf("股票代码长度错误,例如:SZ000001")
-	}
-	switch strings.ToLower(code[:2]) {
-	case ExchangeSH.String():
-		return ExchangeSH, code[2:], nil
-	case ExchangeSZ.String():
-		return ExchangeSZ, code[2:], nil
-	case ExchangeBJ.String():
-		return ExchangeBJ, code[2:], nil
-	default:
-		return 0, "", fmt.Errorf("股票代码错误,例如:SZ000001")
+	s, err := ParseSymbol(code)
+	if err != nil {
+		return 0, "", err
 	}
+	return s.Exchange, s.Code, nil
 }
 
 func FloatUnit(f float64) (float64, string) {
@@ -102,9 +94,9 @@ func GetHourMinute(bs [2]byte) string {
 	return fmt.Sprintf("%02d:%02d", h, m)
 }
 
-func GetTime(bs [4]byte, Type uint8) time.Time {
-	switch Type {
-	case TypeKlineMinute, TypeKlineMinute2, TypeKline5Minute, TypeKline15Minute, TypeKline30Minute, TypeKline60Minute:
+func GetTime(bs [4]byte, Type KlineType) time.Time {
+	switch {
+	case Type.IsMinute():
 
 		yearMonthDay := Uint16(bs[:2])
 		hourMinute := Uint16(bs[2:4])
@@ -113,7 +105,7 @@ func GetTime(bs [4]byte, Type uint8) time.Time {
 		day := int((yearMonthDay % 2048) % 100)
 		hour := int(hourMinute / 60)
 		minute := int(hourMinute % 60)
-		return time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.Local)
+		return time.Date(year, time.Month(month), day, hour, minute, 0, 0, Location)
 
 	default:
 
@@ -121,7 +113,7 @@ func GetTime(bs [4]byte, Type uint8) time.Time {
 		year := int(yearMonthDay / 10000)
 		month := int((yearMonthDay % 10000) / 100)
 		day := int(yearMonthDay % 100)
-		return time.Date(year, time.Month(month), day, 15, 0, 0, 0, time.Local)
+		return time.Date(year, time.Month(month), day, 15, 0, 0, 0, Location)
 
 	}
 }
@@ -273,6 +265,56 @@ func IsBJStock(code string) bool {
 	return len(code) == 8 && strings.ToLower(code[0:2]) == ExchangeBJ.String() && (code[2:4] == "92" || code[2:4] == "43" || code[2:3] == "8")
 }
 
+// IsBShare 是否是B股,示例sh900901,sz200011
+func IsBShare(code string) bool {
+	if len(code) != 8 {
+		return false
+	}
+	code = strings.ToLower(code)
+	return (code[0:2] == ExchangeSH.String() && code[2:5] == "900") ||
+		(code[0:2] == ExchangeSZ.String() && code[2:5] == "200")
+}
+
+// IsRepo 是否是国债逆回购,示例sh204001,sz131810
+func IsRepo(code string) bool {
+	if len(code) != 8 {
+		return false
+	}
+	code = strings.ToLower(code)
+	return (code[0:2] == ExchangeSH.String() && code[2:5] == "204") ||
+		(code[0:2] == ExchangeSZ.String() && code[2:6] == "1318")
+}
+
+// CodeBoard 股票所属的板块,不是股票返回空
+func CodeBoard(code string) string {
+	switch {
+	case IsChiNext(code):
+		return BoardChiNext
+	case IsSTAR(code):
+		return BoardSTAR
+	case IsBJStock(code):
+		return BoardBSE
+	case IsStock(code):
+		return BoardMain
+	}
+	return ""
+}
+
+// IsChiNext 是否是创业板股票,示例sz300750
+func IsChiNext(code string) bool {
+	return IsSZStock(code) && (code[2:5] == "300" || code[2:5] == "301")
+}
+
+// IsSTAR 是否是科创板股票,示例sh688981
+func IsSTAR(code string) bool {
+	return IsSHStock(code) && (code[2:5] == "688" || code[2:5] == "689")
+}
+
+// IsST 名称是否是ST或者*ST股票,示例ST华微,*ST金泰
+func IsST(name string) bool {
+	return strings.Contains(strings.ToUpper(name), "ST")
+}
+
 // IsETF 是否是基金,示例sz159558
 func IsETF(code string) bool {
 	if len(code) != 8 {
@@ -291,29 +333,120 @@ func IsETF(code string) bool {
 	return false
 }
 
-// AddPrefix 添加股票/基金代码前缀,针对股票/基金生效,例如000001,会增加前缀sz000001(平安银行),而不是sh000001(上证指数)
+// IsIndex 是否是指数,示例sh000001,sz399001,bj899050
+func IsIndex(code string) bool {
+	if len(code) != 8 {
+		return false
+	}
+	code = strings.ToLower(code)
+	switch {
+	case code[0:2] == ExchangeSH.String() &&
+		(code[2:5] == "000" || code[2:5] == "880" || code[2:5] == "999"):
+		return true
+
+	case code[0:2] == ExchangeSZ.String() && code[2:5] == "399":
+		return true
+
+	case code[0:2] == ExchangeBJ.String() && code[2:5] == "899":
+		return true
+	}
+	return false
+}
+
+// IsLOF 是否是LOF基金,示例sz161725,sh501018
+func IsLOF(code string) bool {
+	if len(code) != 8 {
+		return false
+	}
+	code = strings.ToLower(code)
+	switch {
+	case code[0:2] == ExchangeSH.String() &&
+		(code[2:5] == "501" || code[2:5] == "502" || code[2:5] == "506"):
+		return true
+
+	case code[0:2] == ExchangeSZ.String() && code[2:4] == "16":
+		return true
+	}
+	return false
+}
+
+// IsREIT 是否是公募REITs,示例sh508000,sz180101
+func IsREIT(code string) bool {
+	if len(code) != 8 {
+		return false
+	}
+	code = strings.ToLower(code)
+	return (code[0:2] == ExchangeSH.String() && code[2:5] == "508") ||
+		(code[0:2] == ExchangeSZ.String() && code[2:5] == "180")
+}
+
+// IsConvertible 是否是可转债,示例sh113050,sz123107
+func IsConvertible(code string) bool {
+	if len(code) != 8 {
+		return false
+	}
+	code = strings.ToLower(code)
+	switch {
+	case code[0:2] == ExchangeSH.String() &&
+		(code[2:5] == "110" || code[2:5] == "111" || code[2:5] == "113" || code[2:5] == "118"):
+		return true
+
+	case code[0:2] == ExchangeSZ.String() &&
+		(code[2:5] == "123" || code[2:5] == "127" || code[2:5] == "128"):
+		return true
+	}
+	return false
+}
+
+// IsBond 是否是债券(不含可转债,包含国债逆回购),示例sh019547,sz101613
+func IsBond(code string) bool {
+	if len(code) != 8 || IsConvertible(code) {
+		return false
+	}
+	code = strings.ToLower(code)
+	switch {
+	case code[0:2] == ExchangeSH.String() &&
+		(code[2:4] == "01" || code[2:4] == "02" || code[2:3] == "1" || code[2:5] == "204"):
+		return true
+
+	case code[0:2] == ExchangeSZ.String() &&
+		(code[2:4] == "10" || code[2:4] == "11" || code[2:4] == "12" || code[2:4] == "13"):
+		return true
+	}
+	return false
+}
+
+// CodeKind 根据代码规则识别证券品种,代码需要带交易所前缀,例sh000001是指数,sz000001是股票
+func CodeKind(code string) string {
+	switch {
+	case IsIndex(code):
+		return KindIndex
+	case IsStock(code):
+		return KindStock
+	case IsBShare(code):
+		return KindBShare
+	case IsLOF(code):
+		return KindLOF
+	case IsETF(code):
+		return KindETF
+	case IsREIT(code):
+		return KindREIT
+	case IsConvertible(code):
+		return KindConvertible
+	case IsRepo(code):
+		return KindRepo
+	case IsBond(code):
+		return KindBond
+	default:
+		return KindOther
+	}
+}
+
+// AddPrefix 添加交易所前缀,统一成小写的格式,例如000001,000001.SZ,1.600000会变成sz000001,sh600000
+// 6位代码按代码规则识别,例000001是sz000001(平安银行),而不是sh000001(上证指数),识别不了的原样返回
 func AddPrefix(code string) string {
-	if len(code) == 6 {
-		switch {
-		case code[:1] == "6":
-			//上海股票
-			code = ExchangeSH.String() + code
-		case code[:1] == "0":
-			//深圳股票
-			code = ExchangeSZ.String() + code
-		case code[:2] == "30":
-			//深圳股票
-			code = ExchangeSZ.String() + code
-		case code[:3] == "510" || code[:3] == "511" || code[:3] == "512" || code[:3] == "513" || code[:3] == "515":
-			//上海基金
-			code = ExchangeSH.String() + code
-		case code[:3] == "159":
-			//深圳基金
-			code = ExchangeSZ.String() + code
-		case code[:1] == "8" || code[:2] == "92" || code[:2] == "43":
-			//北京股票
-			code = ExchangeBJ.String() + code
-		}
+	if s, err := ParseSymbol(code); err == nil {
+		return s.String()
 	}
 	return code
 }
@@ -321,3 +454,20 @@ func AddPrefix(code string) string {
 func minutes(t time.Time) int {
 	return t.Hour()*60 + t.Minute()
 }
+
+// ElapsedTradeMinutes 到时间t为止已经结束的交易分钟数(0-240),9:31算1分钟,午休不计算,15:00之后是240
+func ElapsedTradeMinutes(t time.Time) int {
+	m := minutes(t.In(Location))
+	switch {
+	case m <= 570:
+		return 0
+	case m <= 690:
+		return m - 570
+	case m <= 780:
+		return 120
+	case m <= 900:
+		return m - 780 + 120
+	default:
+		return 240
+	}
+}
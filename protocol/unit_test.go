@@ -23,3 +23,43 @@ func Test_getVolume(t *testing.T) {
 	t.Log(getVolume2(1237966432))
 
 }
+
+func TestCodeKind(t *testing.T) {
+	for code, kind := range map[string]string{
+		"sh000001": KindIndex,
+		"sz399001": KindIndex,
+		"sz000001": KindStock,
+		"sh688001": KindStock,
+		"sh510300": KindETF,
+		"sz159915": KindETF,
+		"sz161725": KindLOF,
+		"sh113050": KindConvertible,
+		"sz123107": KindConvertible,
+		"sh508000": KindREIT,
+		"sh019547": KindBond,
+		"sh900901": KindBShare,
+		"sz200011": KindBShare,
+		"sh204001": KindRepo,
+		"sz131810": KindRepo,
+	} {
+		if k := CodeKind(code); k != kind {
+			t.Errorf("[%s]预期%s,得到%s", code, kind, k)
+		}
+	}
+}
+
+func TestCodeBoard(t *testing.T) {
+	for code, board := range map[string]string{
+		"sz000001": BoardMain,
+		"sh600000": BoardMain,
+		"sz300750": BoardChiNext,
+		"sh688981": BoardSTAR,
+		"bj920001": BoardBSE,
+		"sh000001": "",
+		"sz159915": "",
+	} {
+		if b := CodeBoard(code); b != board {
+			t.Errorf("[%s]预期%s,得到%s", code, board, b)
+		}
+	}
+}
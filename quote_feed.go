@@ -0,0 +1,293 @@
+package tdx
+
+import (
+	"context"
+	"github.com/injoyai/base/safe"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx/protocol"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QuoteChange 盘口的变化类型,可以组合
+type QuoteChange uint8
+
+const (
+	QuoteChangePrice  QuoteChange = 1 << iota //最新价变化
+	QuoteChangeVolume                         //有新的成交量
+	QuoteChangeLevel                          //五档盘口变化
+
+	QuoteChangeAll = QuoteChangePrice | QuoteChangeVolume | QuoteChangeLevel
+)
+
+func (this QuoteChange) Has(c QuoteChange) bool {
+	return this&c != 0
+}
+
+func (this QuoteChange) String() string {
+	s := ""
+	for _, v := range []struct {
+		c    QuoteChange
+		name string
+	}{
+		{QuoteChangePrice, "价格"},
+		{QuoteChangeVolume, "成交量"},
+		{QuoteChangeLevel, "盘口"},
+	} {
+		if this.Has(v.c) {
+			if s != "" {
+				s += "|"
+			}
+			s += v.name
+		}
+	}
+	return s
+}
+
+// QuoteEvent 盘口变化事件
+type QuoteEvent struct {
+	Code   string          //带交易所前缀的代码,例sz000001
+	Change QuoteChange     //变化类型,第一次推送是QuoteChangeAll
+	Quote  *protocol.Quote //当前的盘口
+	Last   *protocol.Quote //上次推送的盘口,第一次推送为nil
+}
+
+// Volume 距离上次推送新增的成交量(手)
+func (this *QuoteEvent) Volume() int {
+	if this.Last == nil {
+		return this.Quote.TotalHand
+	}
+	return this.Quote.TotalHand - this.Last.TotalHand
+}
+
+type QuoteFeedConfig struct {
+	Interval time.Duration //轮询间隔,默认3秒
+	Buffer   int           //事件通道的缓存数量,默认1000
+	Drop     bool          //通道满了之后丢弃事件,默认等待消费(会拖慢轮询)
+}
+
+// NewQuoteFeed 盘口订阅,通过连接池按间隔批量轮询盘口,和上次的快照对比,推送变化事件
+func NewQuoteFeed(p *Pool, cfg QuoteFeedConfig) *QuoteFeed {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second * 3
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 1000
+	}
+	f := &QuoteFeed{
+		pool:   p,
+		Config: cfg,
+		ch:     make(chan *QuoteEvent, cfg.Buffer),
+		last:   make(map[string]*protocol.Quote),
+	}
+	f.Closer = safe.NewCloser().SetCloseFunc(func(err error) error {
+		//等待正在推送的事件结束(已经收到关闭信号),再关闭通道
+		f.sendMu.Lock()
+		defer f.sendMu.Unlock()
+		close(f.ch)
+		return nil
+	})
+	return f
+}
+
+// QuoteFeed 盘口订阅
+// 消费慢的时候,默认会阻塞轮询(背压),设置Drop则丢弃事件,
+// 丢弃时不会更新快照,下次的变化会和最后一次推送的盘口进行对比
+type QuoteFeed struct {
+	pool    *Pool
+	Config  QuoteFeedConfig
+	ch      chan *QuoteEvent
+	codes   []string                   //订阅的代码,保持订阅的顺序
+	last    map[string]*protocol.Quote //最后一次推送的盘口
+	dropped int64                      //丢弃的事件数量
+	mu      sync.Mutex
+	sendMu  sync.RWMutex
+	*safe.Closer
+}
+
+// Events 事件通道,QuoteFeed关闭后通道会被关闭
+func (this *QuoteFeed) Events() <-chan *QuoteEvent {
+	return this.ch
+}
+
+// Dropped 丢弃的事件数量
+func (this *QuoteFeed) Dropped() int64 {
+	return atomic.LoadInt64(&this.dropped)
+}
+
+// Subscribe 订阅代码,已订阅的会忽略
+func (this *QuoteFeed) Subscribe(codes ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, code := range codes {
+		code = ClassifyInstrument(code).Code
+		if _, ok := this.last[code]; ok {
+			continue
+		}
+		this.last[code] = nil
+		this.codes = append(this.codes, code)
+	}
+}
+
+// Unsubscribe 取消订阅
+func (this *QuoteFeed) Unsubscribe(codes ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, code := range codes {
+		code = ClassifyInstrument(code).Code
+		if _, ok := this.last[code]; !ok {
+			continue
+		}
+		delete(this.last, code)
+		for i, v := range this.codes {
+			if v == code {
+				this.codes = append(this.codes[:i], this.codes[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Codes 订阅的代码
+func (this *QuoteFeed) Codes() []string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return append([]string(nil), this.codes...)
+}
+
+// Run 按间隔轮询,直到ctx结束或者关闭
+func (this *QuoteFeed) Run(ctx context.Context) error {
+	t := time.NewTicker(this.Config.Interval)
+	defer t.Stop()
+	for {
+		if err := this.Poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-this.Done():
+			return this.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Poll 轮询一次所有订阅的代码,每批最多protocol.QuoteMaxCodes个,并发数量是连接池的数量,单批的错误只打印日志
+func (this *QuoteFeed) Poll(ctx context.Context) error {
+	codes := this.Codes()
+
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for len(codes) > 0 {
+		n := protocol.QuoteMaxCodes
+		if n > len(codes) {
+			n = len(codes)
+		}
+		batch := codes[:n]
+		codes = codes[n:]
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-this.Done():
+			return this.Err()
+		default:
+		}
+
+		wg.Add(1)
+		err := this.pool.Go(func(c *Client) {
+			defer wg.Done()
+			quotes, err := c.GetQuote(batch...)
+			if err != nil {
+				logs.Err(err)
+				return
+			}
+			for i, q := range quotes {
+				if err := this.publish(ctx, batch[i], q); err != nil {
+					return
+				}
+			}
+		})
+		if err != nil {
+			wg.Done()
+			return err
+		}
+	}
+	return nil
+}
+
+// publish 和上次推送的盘口对比,有变化则推送
+func (this *QuoteFeed) publish(ctx context.Context, code string, q *protocol.Quote) error {
+	this.mu.Lock()
+	last, ok := this.last[code]
+	this.mu.Unlock()
+	if !ok {
+		//已经取消订阅
+		return nil
+	}
+
+	change := QuoteChangeAll
+	if last != nil {
+		change = DiffQuote(last, q)
+	}
+	if change == 0 {
+		return nil
+	}
+
+	e := &QuoteEvent{
+		Code:   code,
+		Change: change,
+		Quote:  q,
+		Last:   last,
+	}
+
+	this.sendMu.RLock()
+	defer this.sendMu.RUnlock()
+	if this.Closed() {
+		return this.Err()
+	}
+
+	if this.Config.Drop {
+		select {
+		case this.ch <- e:
+		default:
+			atomic.AddInt64(&this.dropped, 1)
+			return nil
+		}
+	} else {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-this.Done():
+			return this.Err()
+		case this.ch <- e:
+		}
+	}
+
+	this.mu.Lock()
+	if _, ok := this.last[code]; ok {
+		this.last[code] = q
+	}
+	this.mu.Unlock()
+	return nil
+}
+
+// DiffQuote 对比2个盘口的变化
+func DiffQuote(last, q *protocol.Quote) QuoteChange {
+	if last == nil || q == nil {
+		return QuoteChangeAll
+	}
+	change := QuoteChange(0)
+	if last.K.Close != q.K.Close {
+		change |= QuoteChangePrice
+	}
+	if last.TotalHand != q.TotalHand {
+		change |= QuoteChangeVolume
+	}
+	if last.BuyLevel != q.BuyLevel || last.SellLevel != q.SellLevel {
+		change |= QuoteChangeLevel
+	}
+	return change
+}
@@ -0,0 +1,31 @@
+package tdx
+
+import (
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+)
+
+func TestDiffQuote(t *testing.T) {
+	last := &protocol.Quote{K: protocol.K{Close: 10000}, TotalHand: 100}
+	last.BuyLevel[0] = protocol.PriceLevel{Buy: true, Price: 9990, Number: 50}
+
+	q := *last
+	if c := DiffQuote(last, &q); c != 0 {
+		t.Errorf("预期没有变化,得到%s", c)
+	}
+
+	q.TotalHand = 120
+	q.BuyLevel[0].Number = 30
+	if c := DiffQuote(last, &q); c != QuoteChangeVolume|QuoteChangeLevel {
+		t.Errorf("预期成交量和盘口变化,得到%s", c)
+	}
+
+	q.K.Close = 10010
+	if c := DiffQuote(last, &q); c != QuoteChangeAll {
+		t.Errorf("预期全部变化,得到%s", c)
+	}
+
+	if c := DiffQuote(nil, &q); c != QuoteChangeAll {
+		t.Errorf("第一次预期全部变化,得到%s", c)
+	}
+}
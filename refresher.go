@@ -0,0 +1,143 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"github.com/injoyai/base/safe"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx/protocol"
+	"github.com/robfig/cron/v3"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCodesSpec 代码默认每天9:00:10更新,需要在工作日之后
+	DefaultCodesSpec = "10 0 9 * * *"
+	// DefaultWorkdaySpec 工作日默认每天9:00更新,8点多获取不到今天的数据
+	DefaultWorkdaySpec = "0 0 9 * * *"
+)
+
+type RefresherConfig struct {
+	Jitter        time.Duration //随机延迟的最大值,避免多个实例同时请求服务器,默认不延迟
+	Retry         int           //失败后的重试次数,默认2次,<0不重试
+	RetryInterval time.Duration //重试间隔,默认5分钟
+	OnError       func(err error)
+}
+
+// NewRefresher 定时更新数据,例如代码和工作日,Close之后停止定时任务并中断等待中的重试
+// 由调用方(或者Manage)持有,构造Codes和Workday不会再启动定时任务
+func NewRefresher(cfg RefresherConfig) *Refresher {
+	if cfg.Retry == 0 {
+		cfg.Retry = 2
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = time.Minute * 5
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(err error) { logs.Err(err) }
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Refresher{
+		Config: cfg,
+		cron:   cron.New(cron.WithSeconds(), cron.WithLocation(protocol.Location)),
+		ctx:    ctx,
+	}
+	r.Closer = safe.NewCloser().SetCloseFunc(func(err error) error {
+		cancel()
+		//等待执行中的任务结束
+		<-r.cron.Stop().Done()
+		r.wg.Wait()
+		return nil
+	})
+	r.cron.Start()
+	return r
+}
+
+type Refresher struct {
+	*safe.Closer
+	Config RefresherConfig
+	cron   *cron.Cron
+	ctx    context.Context
+	wg     sync.WaitGroup
+}
+
+// Add 添加定时更新的任务,spec是cron表达式(带秒,北京时间),同一个任务执行中时跳过这次触发
+func (this *Refresher) Add(spec string, f func() error) error {
+	if this.Closed() {
+		return errors.New("refresher closed")
+	}
+	running := make(chan struct{}, 1)
+	_, err := this.cron.AddFunc(spec, func() {
+		select {
+		case running <- struct{}{}:
+			defer func() { <-running }()
+		default:
+			return
+		}
+		if !this.sleep(this.jitter()) {
+			return
+		}
+		if err := this.Run(f); err != nil {
+			this.Config.OnError(err)
+		}
+	})
+	return err
+}
+
+// AddCodes 定时更新代码,spec为空使用DefaultCodesSpec
+func (this *Refresher) AddCodes(c *Codes, spec string) error {
+	if spec == "" {
+		spec = DefaultCodesSpec
+	}
+	return this.Add(spec, func() error { return c.Update() })
+}
+
+// AddWorkday 定时更新工作日,spec为空使用DefaultWorkdaySpec
+func (this *Refresher) AddWorkday(w *Workday, spec string) error {
+	if spec == "" {
+		spec = DefaultWorkdaySpec
+	}
+	return this.Add(spec, w.Update)
+}
+
+// Run 立即执行,失败按配置重试,返回最后一次的错误,Close之后中断重试
+func (this *Refresher) Run(f func() error) (err error) {
+	this.wg.Add(1)
+	defer this.wg.Done()
+	for i := 0; ; i++ {
+		if err = f(); err == nil {
+			return nil
+		}
+		if i >= this.Config.Retry {
+			return err
+		}
+		this.Config.OnError(err)
+		if !this.sleep(this.Config.RetryInterval) {
+			return err
+		}
+	}
+}
+
+func (this *Refresher) jitter() time.Duration {
+	if this.Config.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(this.Config.Jitter)))
+}
+
+// sleep 等待,关闭时返回false
+func (this *Refresher) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return this.ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-this.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
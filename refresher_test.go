@@ -0,0 +1,54 @@
+package tdx
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefresher_Run(t *testing.T) {
+	r := NewRefresher(RefresherConfig{Retry: 2, RetryInterval: time.Millisecond, OnError: func(err error) {}})
+	defer r.Close()
+
+	n := 0
+	err := r.Run(func() error {
+		n++
+		if n < 3 {
+			return errors.New("失败")
+		}
+		return nil
+	})
+	if err != nil || n != 3 {
+		t.Errorf("重试错误: n=%d err=%v", n, err)
+	}
+
+	n = 0
+	if err := r.Run(func() error { n++; return errors.New("失败") }); err == nil || n != 3 {
+		t.Errorf("重试次数错误: n=%d err=%v", n, err)
+	}
+}
+
+func TestRefresher_Close(t *testing.T) {
+	r := NewRefresher(RefresherConfig{RetryInterval: time.Hour, OnError: func(err error) {}})
+	if err := r.Add("* * * * * *", func() error { return errors.New("失败") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("错误的表达式", func() error { return nil }); err == nil {
+		t.Errorf("应该返回表达式错误")
+	}
+	//等待任务触发并进入重试等待,关闭时需要中断等待
+	<-time.After(time.Millisecond * 1100)
+	done := make(chan struct{})
+	go func() {
+		r.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("关闭超时")
+	}
+	if err := r.Add("* * * * * *", func() error { return nil }); err == nil {
+		t.Errorf("关闭之后不能添加任务")
+	}
+}
@@ -0,0 +1,370 @@
+package tdx
+
+import (
+	_ "embed"
+	"github.com/injoyai/tdx/protocol"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultSearchKinds 默认参与搜索的品种,排在前面的优先
+var DefaultSearchKinds = []string{
+	protocol.KindStock,
+	protocol.KindIndex,
+	protocol.KindETF,
+	protocol.KindLOF,
+	protocol.KindREIT,
+	protocol.KindBShare,
+	protocol.KindConvertible,
+	protocol.KindBond,
+}
+
+// SearchConfig 搜索索引的配置
+type SearchConfig struct {
+	Kinds    []string              //参与搜索的品种,排在前面的优先,默认DefaultSearchKinds
+	Delisted bool                  //是否包含已退市的
+	Pinyin   func(r rune) []string //汉字的全拼(小写不带声调,ü写成v,多音字返回多个),用于全拼搜索,默认DefaultPinyin,可以接入github.com/mozillazg/go-pinyin等
+}
+
+// SearchIndex 证券搜索索引,支持代码前缀,拼音首字母(例payh),全拼(例pingan),名称和模糊匹配
+// 创建之后不再修改,代码更新时重新创建
+type SearchIndex struct {
+	cfg   SearchConfig
+	rank  map[string]int
+	items []*searchItem
+}
+
+type searchItem struct {
+	m     *CodeModel
+	full  string     //带交易所前缀的代码,例sz000001
+	name  string     //小写的名称
+	sylls [][]string //名称每个字符的候选拼音(字母和数字是本身),忽略空格和*等符号
+	rank  int        //品种的排名
+}
+
+// NewSearchIndex 创建搜索索引
+func NewSearchIndex(cfg SearchConfig, list []*CodeModel) *SearchIndex {
+	if len(cfg.Kinds) == 0 {
+		cfg.Kinds = DefaultSearchKinds
+	}
+	if cfg.Pinyin == nil {
+		cfg.Pinyin = DefaultPinyin
+	}
+	idx := &SearchIndex{
+		cfg:  cfg,
+		rank: make(map[string]int, len(cfg.Kinds)),
+	}
+	for i, v := range cfg.Kinds {
+		idx.rank[v] = i
+	}
+	for _, m := range list {
+		if m.Delisted && !cfg.Delisted {
+			continue
+		}
+		full := m.FullCode()
+		kind := m.Kind
+		if kind == "" {
+			kind = protocol.CodeKind(full)
+		}
+		rank, ok := idx.rank[kind]
+		if !ok {
+			continue
+		}
+		idx.items = append(idx.items, &searchItem{
+			m:     m,
+			full:  full,
+			name:  strings.ToLower(m.Name),
+			sylls: idx.syllables(m.Name),
+			rank:  rank,
+		})
+	}
+	return idx
+}
+
+// syllables 名称每个字符的候选拼音
+func (this *SearchIndex) syllables(name string) [][]string {
+	ls := [][]string(nil)
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			ls = append(ls, []string{string(r)})
+		case unicode.Is(unicode.Han, r):
+			ss := append([]string(nil), this.cfg.Pinyin(r)...)
+			for _, v := range pinyinInitials(r) {
+				if !hasInitial(ss, v) {
+					ss = append(ss, string(v))
+				}
+			}
+			if len(ss) == 0 {
+				ss = []string{string(r)}
+			}
+			ls = append(ls, ss)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			ls = append(ls, []string{string(r)})
+		}
+	}
+	return ls
+}
+
+// Len 索引的代码数量
+func (this *SearchIndex) Len() int {
+	return len(this.items)
+}
+
+// Search 搜索证券,返回的是副本,按匹配程度排序,相同的按品种排序(例如股票在债券前面)
+// kinds 限制品种,为空不限制,limit<=0不限制数量
+func (this *SearchIndex) Search(keyword string, limit int, kinds ...string) []*CodeModel {
+	if this == nil {
+		return nil
+	}
+	q := normalizeKeyword(keyword)
+	if q == "" {
+		return nil
+	}
+	//带交易所的代码,例sz000001,000001.SZ,精确匹配
+	full := ""
+	if len(q) > 6 {
+		if s, err := protocol.ParseSymbol(keyword); err == nil {
+			full = s.String()
+		}
+	}
+
+	type result struct {
+		item  *searchItem
+		score int
+	}
+	ls := []result(nil)
+	for _, v := range this.items {
+		if len(kinds) > 0 && !hasString(kinds, this.cfg.Kinds[v.rank]) {
+			continue
+		}
+		score := v.score(q, full)
+		if score < 0 {
+			continue
+		}
+		ls = append(ls, result{item: v, score: score})
+	}
+	sort.SliceStable(ls, func(i, j int) bool {
+		if ls[i].score != ls[j].score {
+			return ls[i].score < ls[j].score
+		}
+		if ls[i].item.rank != ls[j].item.rank {
+			return ls[i].item.rank < ls[j].item.rank
+		}
+		return ls[i].item.full < ls[j].item.full
+	})
+
+	if limit > 0 && len(ls) > limit {
+		ls = ls[:limit]
+	}
+	codes := make([]*CodeModel, len(ls))
+	for i, v := range ls {
+		m := *v.item.m
+		codes[i] = &m
+	}
+	return codes
+}
+
+// 匹配程度,越小越好,-1表示不匹配
+const (
+	searchExactCode   = iota //代码完全一致
+	searchExactName          //名称完全一致
+	searchCodePrefix         //代码前缀
+	searchPinyinStart        //拼音从名称开头匹配,例payh,pingan
+	searchNameStart          //名称前缀
+	searchNameContain        //名称包含,拼音从名称中间匹配
+	searchFuzzy              //模糊匹配,代码包含,拼音首字母或名称是子序列
+)
+
+func (this *searchItem) score(q, full string) int {
+	code := this.m.Code
+	switch {
+	case q == code || q == this.full || full == this.full:
+		return searchExactCode
+	case q == this.name:
+		return searchExactName
+	case strings.HasPrefix(code, q) || (len(q) > 2 && strings.HasPrefix(this.full, q)):
+		return searchCodePrefix
+	case matchPinyin(this.sylls, 0, q):
+		return searchPinyinStart
+	case strings.HasPrefix(this.name, q):
+		return searchNameStart
+	case strings.Contains(this.name, q):
+		return searchNameContain
+	}
+	for i := 1; i < len(this.sylls); i++ {
+		if matchPinyin(this.sylls, i, q) {
+			return searchNameContain
+		}
+	}
+	if strings.Contains(code, q) || subsequence(this.sylls, q) || subsequenceString(this.name, q) {
+		return searchFuzzy
+	}
+	return -1
+}
+
+// matchPinyin 从第i个字开始连续匹配拼音,每个字可以是全拼或者首字母,最后一个字可以是全拼的前缀
+// 例如平安银行可以匹配payh,pingan,pinganyh,pay
+func matchPinyin(sylls [][]string, i int, q string) bool {
+	if q == "" {
+		return true
+	}
+	if i >= len(sylls) {
+		return false
+	}
+	for _, s := range sylls[i] {
+		switch {
+		case strings.HasPrefix(q, s):
+			if matchPinyin(sylls, i+1, q[len(s):]) {
+				return true
+			}
+		case strings.HasPrefix(s, q):
+			return true
+		}
+		if len(s) > 1 && q[0] == s[0] && matchPinyin(sylls, i+1, q[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// subsequence 首字母是否按顺序包含q,例如payh匹配平安银行,pyh也匹配
+func subsequence(sylls [][]string, q string) bool {
+	j := 0
+	for i := 0; i < len(sylls) && j < len(q); i++ {
+		for _, s := range sylls[i] {
+			if s[0] == q[j] {
+				j++
+				break
+			}
+		}
+	}
+	return j == len(q)
+}
+
+func subsequenceString(s, q string) bool {
+	rs := []rune(q)
+	j := 0
+	for _, r := range s {
+		if j < len(rs) && r == rs[j] {
+			j++
+		}
+	}
+	return j == len(rs)
+}
+
+// normalizeKeyword 关键字转小写,去掉空格和*等符号
+func normalizeKeyword(s string) string {
+	b := strings.Builder{}
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == ':' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func hasString(ls []string, s string) bool {
+	for _, v := range ls {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasInitial(ls []string, b byte) bool {
+	for _, v := range ls {
+		if v[0] == b {
+			return true
+		}
+	}
+	return false
+}
+
+// searchInitials 常用汉字(U+4E00-U+9FFF)的拼音首字母,一个字符对应一个汉字,_表示未知,来自CLDR的拼音排序
+//
+//go:embed search_initials.txt
+var searchInitials string
+
+// searchPolyphones 证券名称里常见的多音字的全部读音,补充排序数据和拼音表里没有的读音,例如银行,重庆,长江,厦门
+var searchPolyphones = map[rune][]string{
+	'行': {"hang", "xing"},
+	'长': {"chang", "zhang"},
+	'重': {"chong", "zhong"},
+	'厦': {"xia", "sha"},
+	'藏': {"zang", "cang"},
+	'乐': {"le", "yue"},
+	'传': {"chuan", "zhuan"},
+	'朝': {"chao", "zhao"},
+	'会': {"hui", "kuai"},
+	'单': {"dan", "shan"},
+	'区': {"qu", "ou"},
+	'曾': {"zeng", "ceng"},
+	'石': {"shi", "dan"},
+	'参': {"can", "shen", "cen"},
+	'蔚': {"wei", "yu"},
+	'便': {"bian", "pian"},
+	'校': {"xiao", "jiao"},
+	'解': {"jie", "xie"},
+	'柏': {"bai", "bo"},
+	'晟': {"sheng", "cheng"},
+	'调': {"tiao", "diao"},
+	'系': {"xi", "ji"},
+}
+
+var searchInitialsTable = strings.ReplaceAll(searchInitials, "\n", "")
+
+// pinyinInitials 汉字的拼音首字母,多音字可能有多个,未知返回nil
+func pinyinInitials(r rune) []byte {
+	if ls, ok := searchPolyphones[r]; ok {
+		bs := []byte(nil)
+		for _, v := range ls {
+			if strings.IndexByte(string(bs), v[0]) < 0 {
+				bs = append(bs, v[0])
+			}
+		}
+		return bs
+	}
+	i := int(r - 0x4E00)
+	if i < 0 || i >= len(searchInitialsTable) || searchInitialsTable[i] == '_' {
+		return nil
+	}
+	return []byte{searchInitialsTable[i]}
+}
+
+// searchPinyin 常用汉字(U+4E00-U+9FFF)的全拼,每行是一个读音和这个读音的全部汉字,每个汉字只有一个主要的读音,
+// 多音字见searchPolyphones,由ICU的Han-Latin转换生成(uconv -x Han-Latin),去掉了声调
+//
+//go:embed search_pinyin.txt
+var searchPinyin string
+
+var (
+	searchPinyinOnce  sync.Once
+	searchPinyinTable map[rune]string
+)
+
+// DefaultPinyin 内置的汉字全拼,多音字返回多个,未知返回nil,SearchConfig.Pinyin的默认值
+func DefaultPinyin(r rune) []string {
+	if ls, ok := searchPolyphones[r]; ok {
+		return ls
+	}
+	searchPinyinOnce.Do(func() {
+		searchPinyinTable = make(map[rune]string, 21000)
+		for _, line := range strings.Split(searchPinyin, "\n") {
+			py, chars, ok := strings.Cut(line, " ")
+			if !ok {
+				continue
+			}
+			for _, c := range chars {
+				searchPinyinTable[c] = py
+			}
+		}
+	})
+	if s, ok := searchPinyinTable[r]; ok {
+		return []string{s}
+	}
+	return nil
+}
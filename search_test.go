@@ -0,0 +1,99 @@
+package tdx
+
+import (
+	"testing"
+)
+
+func TestCodes_Search(t *testing.T) {
+	list := []*CodeModel{
+		{Exchange: "sz", Code: "000001", Name: "平安银行"},
+		{Exchange: "sh", Code: "000001", Name: "上证指数"},
+		{Exchange: "sh", Code: "600000", Name: "浦发银行"},
+		{Exchange: "sz", Code: "300750", Name: "宁德时代"},
+		{Exchange: "sh", Code: "600519", Name: "贵州茅台"},
+		{Exchange: "sz", Code: "123001", Name: "蓝标转债"},
+		{Exchange: "sz", Code: "300058", Name: "蓝色光标"},
+		{Exchange: "sh", Code: "601988", Name: "中国银行"},
+		{Exchange: "sz", Code: "000002", Name: "万科A", Delisted: true},
+	}
+	for _, v := range list {
+		v.classify()
+	}
+	c := &Codes{}
+	c.swap(newCodesSnapshot(list, nil))
+
+	first := func(keyword string, kinds ...string) string {
+		ls := c.Search(keyword, 10, kinds...)
+		if len(ls) == 0 {
+			return ""
+		}
+		return ls[0].FullCode()
+	}
+
+	for _, v := range []struct {
+		keyword string
+		want    string
+	}{
+		{"payh", "sz000001"},
+		{"PAYH", "sz000001"},
+		{"000001", "sz000001"}, //股票在指数前面
+		{"sh000001", "sh000001"},
+		{"000001.SH", "sh000001"},
+		{"6005", "sh600519"},
+		{"gzmt", "sh600519"},
+		{"茅台", "sh600519"},
+		{"ndsd", "sz300750"},
+		{"zgyh", "sh601988"},
+		{"蓝", "sz300058"}, //股票在债券前面
+		{"wka", ""},       //已退市
+	} {
+		if got := first(v.keyword); got != v.want {
+			t.Errorf("搜索[%s]: 期望 %s, 得到 %s", v.keyword, v.want, got)
+		}
+	}
+
+	if got := first("000001", "index"); got != "sh000001" {
+		t.Errorf("限制品种: %s", got)
+	}
+	if ls := c.Search("yh", 0); len(ls) != 3 {
+		t.Errorf("模糊匹配的数量错误: %d", len(ls))
+	}
+
+	//内置的全拼
+	for keyword, want := range map[string]string{
+		"pingan":          "sz000001",
+		"guizhoumaotai":   "sh600519",
+		"zhongguoyinhang": "sh601988",
+		"ningdeshidai":    "sz300750",
+		"pufayh":          "sh600000",
+	} {
+		if got := first(keyword); got != want {
+			t.Errorf("内置全拼[%s]: 期望 %s, 得到 %s", keyword, want, got)
+		}
+	}
+
+	//自定义全拼
+	c.SetSearchConfig(SearchConfig{Pinyin: func(r rune) []string {
+		return map[rune][]string{'平': {"ping"}, '安': {"an"}, '银': {"yin"}, '行': {"hang", "xing"}}[r]
+	}})
+	for _, v := range []string{"pingan", "pinganyinhang", "pingayh", "pinganyin"} {
+		if got := first(v); got != "sz000001" {
+			t.Errorf("全拼[%s]: %s", v, got)
+		}
+	}
+}
+
+func TestDefaultPinyin(t *testing.T) {
+	for r, want := range map[rune]string{'平': "ping", '茅': "mao", '绿': "lv", '行': "hang,xing", '重': "chong,zhong", 'a': ""} {
+		got := ""
+		for i, v := range DefaultPinyin(r) {
+			if i > 0 {
+				got += ","
+			}
+			got += v
+		}
+		if got != want {
+			t.Errorf("%c: 期望 %s, 得到 %s", r, want, got)
+		}
+	}
+}
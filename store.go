@@ -0,0 +1,211 @@
+package tdx
+
+import (
+	"github.com/injoyai/conv"
+	"os"
+	"path/filepath"
+	"xorm.io/core"
+	"xorm.io/xorm"
+)
+
+// CodeStore 代码信息的存储,Codes通过这个接口读写数据,可以是数据库,内存或者文件
+type CodeStore interface {
+	// LoadCodes 加载全部代码,返回的数据会被修改,需要返回副本
+	LoadCodes() ([]*CodeModel, error)
+	// SaveCodes 保存代码和新增的名称变更记录,需要同时成功或者同时失败(同一个事务)
+	// all是全部的代码,insert是新增的,update是有变化的,按需使用,histories是新增的名称变更记录
+	SaveCodes(all, insert, update []*CodeModel, histories []*CodeHistoryModel) error
+	// LoadHistory 加载全部的名称变更记录
+	LoadHistory() ([]*CodeHistoryModel, error)
+	// UpdateTime 获取上次从服务器更新的时间,秒级时间戳,没有更新过返回0
+	UpdateTime() (int64, error)
+	// SetUpdateTime 设置从服务器更新的时间
+	SetUpdateTime(t int64) error
+}
+
+// CalendarStore 交易日历(工作日)的存储
+type CalendarStore interface {
+	// LoadWorkdays 加载全部的工作日
+	LoadWorkdays() ([]*WorkdayModel, error)
+	// AddWorkdays 添加工作日
+	AddWorkdays(ls []*WorkdayModel) error
+}
+
+func newSqliteEngine(filename string) (*xorm.Engine, error) {
+	//如果文件夹不存在就创建
+	dir, _ := filepath.Split(filename)
+	_ = os.MkdirAll(dir, 0777)
+
+	//连接数据库
+	db, err := xorm.NewEngine("sqlite", filename)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMapper(core.SameMapper{})
+	db.DB().SetMaxOpenConns(1)
+	return db, nil
+}
+
+func newMysqlEngine(dsn string) (*xorm.Engine, error) {
+	db, err := xorm.NewEngine("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMapper(core.SameMapper{})
+	return db, nil
+}
+
+// NewCodeStoreSqlite sqlite存储,filename为空使用默认的文件
+func NewCodeStoreSqlite(filename string) (*XormCodeStore, error) {
+	filename = conv.Select(filename == "", filepath.Join(DefaultDatabaseDir, "codes.db"), filename)
+	db, err := newSqliteEngine(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewCodeStoreXorm(db)
+}
+
+// NewCodeStoreMysql mysql存储
+func NewCodeStoreMysql(dsn string) (*XormCodeStore, error) {
+	db, err := newMysqlEngine(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewCodeStoreXorm(db)
+}
+
+// NewCodeStoreXorm 通用的数据库存储,只写入新增和有变化的代码,mysql批量插入,其他数据库(sqlite,postgres等)逐条插入
+func NewCodeStoreXorm(db *xorm.Engine) (*XormCodeStore, error) {
+	if err := db.Sync2(new(CodeModel), new(UpdateModel), new(CodeHistoryModel)); err != nil {
+		return nil, err
+	}
+	return &XormCodeStore{DB: db}, nil
+}
+
+type XormCodeStore struct {
+	DB *xorm.Engine
+}
+
+// Close 关闭数据库,NewCodeStoreXorm传入的数据库也会被关闭
+func (this *XormCodeStore) Close() error {
+	return this.DB.Close()
+}
+
+func (this *XormCodeStore) LoadCodes() ([]*CodeModel, error) {
+	list := []*CodeModel(nil)
+	err := this.DB.Find(&list)
+	return list, err
+}
+
+func (this *XormCodeStore) SaveCodes(all, insert, update []*CodeModel, histories []*CodeHistoryModel) error {
+	return NewSessionFunc(this.DB, func(session *xorm.Session) error {
+		switch this.DB.Dialect().URI().DBType {
+		case "mysql":
+			//批量插入,第一次初始化时数量较多
+			batchSize := 3000 // 8000(2m16s) 5000(43s) 3000(11s) 1000(59s)
+			for i := 0; i < len(insert); i += batchSize {
+				end := i + batchSize
+				if end > len(insert) {
+					end = len(insert)
+				}
+				if _, err := session.Insert(conv.Array(insert[i:end])); err != nil {
+					return err
+				}
+			}
+		default:
+			for _, v := range insert {
+				if _, err := session.Insert(v); err != nil {
+					return err
+				}
+			}
+		}
+		//只更新有变化的代码
+		for _, v := range update {
+			if _, err := session.Where("Exchange=? and Code=? ", v.Exchange, v.Code).
+				Cols("Name,Multiple,Decimal,LastPrice,Kind,Board,ST,StarST,ListDate,LastSeen,Delisted").Update(v); err != nil {
+				return err
+			}
+		}
+		if len(histories) > 0 {
+			if _, err := session.Insert(histories); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (this *XormCodeStore) LoadHistory() ([]*CodeHistoryModel, error) {
+	list := []*CodeHistoryModel(nil)
+	err := this.DB.Asc("Date", "ID").Find(&list)
+	return list, err
+}
+
+func (this *XormCodeStore) UpdateTime() (int64, error) {
+	update := new(UpdateModel)
+	_, err := this.DB.Where("`Key`=?", "codes").Get(update)
+	return update.Time, err
+}
+
+func (this *XormCodeStore) SetUpdateTime(t int64) error {
+	has, err := this.DB.Where("`Key`=?", "codes").Exist(new(UpdateModel))
+	if err != nil {
+		return err
+	}
+	if !has {
+		_, err = this.DB.Insert(&UpdateModel{Key: "codes", Time: t})
+		return err
+	}
+	_, err = this.DB.Where("`Key`=?", "codes").Update(&UpdateModel{Time: t})
+	return err
+}
+
+// NewCalendarStoreSqlite sqlite存储,filename为空使用默认的文件
+func NewCalendarStoreSqlite(filename string) (*XormCalendarStore, error) {
+	filename = conv.Select(filename == "", filepath.Join(DefaultDatabaseDir, "workday.db"), filename)
+	db, err := newSqliteEngine(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewCalendarStoreXorm(db)
+}
+
+// NewCalendarStoreMysql mysql存储
+func NewCalendarStoreMysql(dsn string) (*XormCalendarStore, error) {
+	db, err := newMysqlEngine(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewCalendarStoreXorm(db)
+}
+
+// NewCalendarStoreXorm 通用的数据库存储
+func NewCalendarStoreXorm(db *xorm.Engine) (*XormCalendarStore, error) {
+	if err := db.Sync2(new(WorkdayModel)); err != nil {
+		return nil, err
+	}
+	return &XormCalendarStore{DB: db}, nil
+}
+
+type XormCalendarStore struct {
+	DB *xorm.Engine
+}
+
+// Close 关闭数据库,NewCalendarStoreXorm传入的数据库也会被关闭
+func (this *XormCalendarStore) Close() error {
+	return this.DB.Close()
+}
+
+func (this *XormCalendarStore) LoadWorkdays() ([]*WorkdayModel, error) {
+	all := []*WorkdayModel(nil)
+	err := this.DB.Asc("Date").Find(&all)
+	return all, err
+}
+
+func (this *XormCalendarStore) AddWorkdays(ls []*WorkdayModel) error {
+	if len(ls) == 0 {
+		return nil
+	}
+	_, err := this.DB.Insert(ls)
+	return err
+}
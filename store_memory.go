@@ -0,0 +1,180 @@
+package tdx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// NewCodeStoreMemory 内存存储,例如用于测试或者不需要持久化的场景
+func NewCodeStoreMemory() *MemoryCodeStore {
+	return &MemoryCodeStore{}
+}
+
+type MemoryCodeStore struct {
+	Codes      []*CodeModel        `json:"codes"`
+	History    []*CodeHistoryModel `json:"history"`
+	UpdateUnix int64               `json:"updateTime"`
+	mu         sync.RWMutex
+}
+
+func (this *MemoryCodeStore) LoadCodes() ([]*CodeModel, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	ls := make([]*CodeModel, len(this.Codes))
+	for i, v := range this.Codes {
+		c := *v
+		ls[i] = &c
+	}
+	return ls, nil
+}
+
+func (this *MemoryCodeStore) SaveCodes(all, insert, update []*CodeModel, histories []*CodeHistoryModel) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	ls := make([]*CodeModel, len(all))
+	for i, v := range all {
+		c := *v
+		ls[i] = &c
+	}
+	this.Codes = ls
+	if len(histories) > 0 {
+		this.History = append(this.History, histories...)
+		sort.SliceStable(this.History, func(i, j int) bool {
+			return this.History[i].Date < this.History[j].Date
+		})
+	}
+	return nil
+}
+
+func (this *MemoryCodeStore) LoadHistory() ([]*CodeHistoryModel, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return append([]*CodeHistoryModel(nil), this.History...), nil
+}
+
+func (this *MemoryCodeStore) UpdateTime() (int64, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.UpdateUnix, nil
+}
+
+func (this *MemoryCodeStore) SetUpdateTime(t int64) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.UpdateUnix = t
+	return nil
+}
+
+// NewCodeStoreJSON json文件存储,数据都在内存中,每次修改后整体写入文件,适合没有数据库的场景
+func NewCodeStoreJSON(filename string) (*JSONCodeStore, error) {
+	s := &JSONCodeStore{MemoryCodeStore: NewCodeStoreMemory(), Filename: filename}
+	if err := readJSONFile(filename, s.MemoryCodeStore); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type JSONCodeStore struct {
+	*MemoryCodeStore
+	Filename string
+}
+
+func (this *JSONCodeStore) SaveCodes(all, insert, update []*CodeModel, histories []*CodeHistoryModel) error {
+	if err := this.MemoryCodeStore.SaveCodes(all, insert, update, histories); err != nil {
+		return err
+	}
+	return this.save()
+}
+
+func (this *JSONCodeStore) SetUpdateTime(t int64) error {
+	if err := this.MemoryCodeStore.SetUpdateTime(t); err != nil {
+		return err
+	}
+	return this.save()
+}
+
+func (this *JSONCodeStore) save() error {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return writeJSONFile(this.Filename, this.MemoryCodeStore)
+}
+
+// NewCalendarStoreMemory 内存存储
+func NewCalendarStoreMemory() *MemoryCalendarStore {
+	return &MemoryCalendarStore{}
+}
+
+type MemoryCalendarStore struct {
+	Workdays []*WorkdayModel `json:"workdays"`
+	mu       sync.RWMutex
+}
+
+func (this *MemoryCalendarStore) LoadWorkdays() ([]*WorkdayModel, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return append([]*WorkdayModel(nil), this.Workdays...), nil
+}
+
+func (this *MemoryCalendarStore) AddWorkdays(ls []*WorkdayModel) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.Workdays = append(this.Workdays, ls...)
+	sort.SliceStable(this.Workdays, func(i, j int) bool {
+		return this.Workdays[i].Date < this.Workdays[j].Date
+	})
+	return nil
+}
+
+// NewCalendarStoreJSON json文件存储
+func NewCalendarStoreJSON(filename string) (*JSONCalendarStore, error) {
+	s := &JSONCalendarStore{MemoryCalendarStore: NewCalendarStoreMemory(), Filename: filename}
+	if err := readJSONFile(filename, s.MemoryCalendarStore); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type JSONCalendarStore struct {
+	*MemoryCalendarStore
+	Filename string
+}
+
+func (this *JSONCalendarStore) AddWorkdays(ls []*WorkdayModel) error {
+	if err := this.MemoryCalendarStore.AddWorkdays(ls); err != nil {
+		return err
+	}
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return writeJSONFile(this.Filename, this.MemoryCalendarStore)
+}
+
+// readJSONFile 读取json文件,文件不存在则忽略
+func readJSONFile(filename string, v any) error {
+	bs, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
+
+// writeJSONFile 先写临时文件再重命名,避免写到一半程序退出导致文件损坏
+func writeJSONFile(filename string, v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dir, _ := filepath.Split(filename)
+	if dir != "" {
+		_ = os.MkdirAll(dir, 0777)
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,123 @@
+package tdx
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCodeStore(t *testing.T) {
+	dir := t.TempDir()
+	sqlite, err := NewCodeStoreSqlite(filepath.Join(dir, "codes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.DB.Close()
+	json, err := NewCodeStoreJSON(filepath.Join(dir, "codes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]CodeStore{
+		"memory": NewCodeStoreMemory(),
+		"json":   json,
+		"sqlite": sqlite,
+	} {
+		a := &CodeModel{Exchange: "sz", Code: "000001", Name: "平安银行"}
+		b := &CodeModel{Exchange: "sh", Code: "600000", Name: "浦发银行"}
+		if err := s.SaveCodes([]*CodeModel{a, b}, []*CodeModel{a, b}, nil, nil); err != nil {
+			t.Fatal(name, err)
+		}
+		b.Name = "ST浦发"
+		b.ListDate = 1000
+		if err := s.SaveCodes([]*CodeModel{a, b}, nil, []*CodeModel{b}, []*CodeHistoryModel{
+			{Exchange: "sh", Code: "600000", OldName: "浦发银行", Name: "ST浦发", Date: 1},
+		}); err != nil {
+			t.Fatal(name, err)
+		}
+		if err := s.SetUpdateTime(100); err != nil {
+			t.Fatal(name, err)
+		}
+
+		codes, err := s.LoadCodes()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		m := map[string]string{}
+		for _, v := range codes {
+			m[v.FullCode()] = v.Name
+			if v.FullCode() == "sh600000" && v.ListDate != 1000 {
+				t.Errorf("%s: 上市日期没有保存: %d", name, v.ListDate)
+			}
+		}
+		if len(codes) != 2 || m["sh600000"] != "ST浦发" || m["sz000001"] != "平安银行" {
+			t.Errorf("%s: codes=%v", name, m)
+		}
+		history, err := s.LoadHistory()
+		if err != nil || len(history) != 1 || history[0].OldName != "浦发银行" {
+			t.Errorf("%s: history=%v err=%v", name, history, err)
+		}
+		if u, err := s.UpdateTime(); err != nil || u != 100 {
+			t.Errorf("%s: updateTime=%d err=%v", name, u, err)
+		}
+	}
+
+	//名称变更记录保存失败时,代码的修改也要回滚
+	a := &CodeModel{Exchange: "sz", Code: "000001", Name: "ST平安"}
+	h := &CodeHistoryModel{ID: 1, Exchange: "sz", Code: "000001", OldName: "平安银行", Name: "ST平安", Date: 2}
+	if err := sqlite.SaveCodes(nil, nil, []*CodeModel{a}, []*CodeHistoryModel{h}); err == nil {
+		t.Error("预期主键重复的错误")
+	}
+	if codes, _ := sqlite.LoadCodes(); len(codes) != 2 || codes[0].Name != "平安银行" {
+		t.Errorf("sqlite没有回滚代码的修改: %v", codes[0].Name)
+	}
+
+	//重新打开json文件
+	json, err = NewCodeStoreJSON(filepath.Join(dir, "codes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes, _ := json.LoadCodes(); len(codes) != 2 {
+		t.Errorf("json重新加载的代码数量错误: %d", len(codes))
+	}
+	if u, _ := json.UpdateTime(); u != 100 {
+		t.Errorf("json重新加载的更新时间错误: %d", u)
+	}
+}
+
+func TestCalendarStore(t *testing.T) {
+	dir := t.TempDir()
+	sqlite, err := NewCalendarStoreSqlite(filepath.Join(dir, "workday.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.DB.Close()
+	json, err := NewCalendarStoreJSON(filepath.Join(dir, "workday.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]CalendarStore{
+		"memory": NewCalendarStoreMemory(),
+		"json":   json,
+		"sqlite": sqlite,
+	} {
+		if err := s.AddWorkdays([]*WorkdayModel{{Date: "20240103"}, {Date: "20240102"}}); err != nil {
+			t.Fatal(name, err)
+		}
+		ls, err := s.LoadWorkdays()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(ls) != 2 || ls[0].Date != "20240102" {
+			t.Errorf("%s: %v", name, ls)
+		}
+	}
+
+	json, err = NewCalendarStoreJSON(filepath.Join(dir, "workday.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls, _ := json.LoadWorkdays(); len(ls) != 2 {
+		t.Errorf("json重新加载的工作日数量错误: %d", len(ls))
+	}
+}
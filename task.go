@@ -0,0 +1,368 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/injoyai/logs"
+	"sort"
+	"sync"
+	"time"
+	"xorm.io/xorm"
+)
+
+// Task 任务,例如extend.PullKline,通过Manage.Tasks注册后可以定时执行或者手动执行
+type Task interface {
+	Name() string                             //任务名称,需要唯一
+	Run(ctx context.Context, m *Manage) error //执行任务,需要响应ctx的取消
+}
+
+const (
+	TaskRunning  = "running"  //执行中
+	TaskSuccess  = "success"  //成功
+	TaskFailed   = "failed"   //失败
+	TaskCanceled = "canceled" //取消,例如程序关闭
+)
+
+var (
+	ErrTaskRunning  = errors.New("任务正在执行中")
+	ErrTaskNotFound = errors.New("任务不存在")
+	ErrTaskClosed   = errors.New("任务执行器已经关闭")
+)
+
+// TaskOption 任务的执行选项
+type TaskOption struct {
+	Spec          string        //定时执行的cron表达式(带秒,北京时间),为空只能手动执行
+	Workday       bool          //定时执行时只在工作日执行
+	Retry         int           //失败后的重试次数,默认不重试
+	RetryInterval time.Duration //重试间隔,默认1分钟
+}
+
+// TaskRunModel 任务的执行记录,每次尝试(包括重试)一条记录
+type TaskRunModel struct {
+	ID      int64  `json:"id"`                    //主键
+	Name    string `json:"name" xorm:"index"`     //任务名称
+	Trigger string `json:"trigger"`               //触发方式,cron或者manual
+	Attempt int    `json:"attempt"`               //第几次尝试,从1开始
+	Status  string `json:"status"`                //状态,TaskRunning等
+	Error   string `json:"error"`                 //错误信息
+	Done    int    `json:"done"`                  //进度,已完成的数量
+	Total   int    `json:"total"`                 //进度,总数量
+	Start   int64  `json:"start"`                 //开始时间
+	End     int64  `json:"end"`                   //结束时间,执行中为0
+	InDate  int64  `json:"inDate" xorm:"created"` //创建时间
+}
+
+func (*TaskRunModel) TableName() string {
+	return "task_run"
+}
+
+// Finished 是否执行成功
+func (this *TaskRunModel) Finished() bool {
+	return this.Status == TaskSuccess
+}
+
+// NewTaskRunner 任务执行器,db用于记录执行历史,为空只保存在内存中
+// 定时执行使用Manage.Cron,需要启动Manage.Cron
+func NewTaskRunner(m *Manage, db *xorm.Engine) (*TaskRunner, error) {
+	if db != nil {
+		if err := db.Sync2(new(TaskRunModel)); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TaskRunner{
+		m:      m,
+		db:     db,
+		tasks:  make(map[string]*taskEntry),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+type TaskRunner struct {
+	m      *Manage
+	db     *xorm.Engine
+	tasks  map[string]*taskEntry
+	mu     sync.RWMutex
+	wg     sync.WaitGroup
+	ctx    context.Context //关闭时取消所有执行中的任务
+	cancel context.CancelFunc
+}
+
+type taskEntry struct {
+	task    Task
+	option  TaskOption
+	running chan struct{} //容量为1,防止同一个任务重叠执行
+	last    *TaskRunModel //最后一次的执行记录
+}
+
+// Register 注册任务,配置了Spec的定时执行
+func (this *TaskRunner) Register(task Task, option TaskOption) error {
+	if option.RetryInterval <= 0 {
+		option.RetryInterval = time.Minute
+	}
+	name := task.Name()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if _, ok := this.tasks[name]; ok {
+		return fmt.Errorf("任务[%s]已经注册", name)
+	}
+	if option.Spec != "" {
+		_, err := this.m.Cron.AddFunc(option.Spec, func() {
+			if option.Workday && !this.m.Workday.TodayIs() {
+				return
+			}
+			if err := this.run(this.ctx, name, "cron"); err != nil && err != ErrTaskRunning && err != ErrTaskClosed {
+				logs.Errf("任务[%s]执行失败: %v\n", name, err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	this.tasks[name] = &taskEntry{
+		task:    task,
+		option:  option,
+		running: make(chan struct{}, 1),
+	}
+	return nil
+}
+
+// Names 已注册的任务名称
+func (this *TaskRunner) Names() []string {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	ls := make([]string, 0, len(this.tasks))
+	for k := range this.tasks {
+		ls = append(ls, k)
+	}
+	sort.Strings(ls)
+	return ls
+}
+
+// Running 任务是否正在执行
+func (this *TaskRunner) Running(name string) bool {
+	e := this.get(name)
+	return e != nil && len(e.running) > 0
+}
+
+// Run 立即执行任务,等待执行完成(包括重试),任务正在执行时返回ErrTaskRunning,已经关闭返回ErrTaskClosed
+func (this *TaskRunner) Run(ctx context.Context, name string) error {
+	return this.run(ctx, name, "manual")
+}
+
+// Go 在后台执行任务,任务正在执行时返回ErrTaskRunning,已经关闭返回ErrTaskClosed
+func (this *TaskRunner) Go(name string) error {
+	e := this.get(name)
+	if e == nil {
+		return ErrTaskNotFound
+	}
+	if this.Running(name) {
+		return ErrTaskRunning
+	}
+	if err := this.add(); err != nil {
+		return err
+	}
+	go func() {
+		defer this.wg.Done()
+		if err := this.run(this.ctx, name, "manual"); err != nil && err != ErrTaskRunning && err != ErrTaskClosed {
+			logs.Errf("任务[%s]执行失败: %v\n", name, err)
+		}
+	}()
+	return nil
+}
+
+// add 增加执行中的计数,已经关闭返回ErrTaskClosed
+// 和Close使用同一个锁,保证Close开始等待之后不会再有新的执行
+func (this *TaskRunner) add() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.ctx.Err() != nil {
+		return ErrTaskClosed
+	}
+	this.wg.Add(1)
+	return nil
+}
+
+func (this *TaskRunner) get(name string) *taskEntry {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.tasks[name]
+}
+
+func (this *TaskRunner) run(ctx context.Context, name, trigger string) error {
+	e := this.get(name)
+	if e == nil {
+		return ErrTaskNotFound
+	}
+	select {
+	case e.running <- struct{}{}:
+		defer func() { <-e.running }()
+	default:
+		return ErrTaskRunning
+	}
+	if err := this.add(); err != nil {
+		return err
+	}
+	defer this.wg.Done()
+
+	//关闭执行器时取消
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-this.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for attempt := 1; ; attempt++ {
+		err := this.attempt(ctx, e, trigger, attempt)
+		if err == nil || ctx.Err() != nil || attempt > e.option.Retry {
+			return err
+		}
+		logs.Errf("任务[%s]第%d次执行失败,%s后重试: %v\n", name, attempt, e.option.RetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(e.option.RetryInterval):
+		}
+	}
+}
+
+func (this *TaskRunner) attempt(ctx context.Context, e *taskEntry, trigger string, attempt int) (err error) {
+	r := &TaskRun{
+		runner: this,
+		model: &TaskRunModel{
+			Name:    e.task.Name(),
+			Trigger: trigger,
+			Attempt: attempt,
+			Status:  TaskRunning,
+			Start:   time.Now().Unix(),
+		},
+	}
+	this.mu.Lock()
+	e.last = r.model
+	this.mu.Unlock()
+	if this.db != nil {
+		if _, err := this.db.Insert(r.model); err != nil {
+			logs.Err(err)
+		}
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+		r.finish(ctx, err)
+	}()
+	return e.task.Run(context.WithValue(ctx, taskRunKey{}, r), this.m)
+}
+
+// Last 任务最后一次的执行记录,优先从内存获取,没有则查询数据库,没有执行过返回nil
+// 例如判断昨晚的拉取任务是否完成: Last(name).Finished()
+func (this *TaskRunner) Last(name string) (*TaskRunModel, error) {
+	this.mu.RLock()
+	e, ok := this.tasks[name]
+	if ok && e.last != nil {
+		m := *e.last
+		this.mu.RUnlock()
+		return &m, nil
+	}
+	this.mu.RUnlock()
+	ls, err := this.History(name, 1)
+	if err != nil || len(ls) == 0 {
+		return nil, err
+	}
+	return ls[0], nil
+}
+
+// History 任务的执行记录,按时间倒序,limit<=0不限制
+func (this *TaskRunner) History(name string, limit int) ([]*TaskRunModel, error) {
+	if this.db == nil {
+		return nil, nil
+	}
+	ls := []*TaskRunModel(nil)
+	session := this.db.Where("Name=?", name).Desc("ID")
+	if limit > 0 {
+		session.Limit(limit)
+	}
+	err := session.Find(&ls)
+	return ls, err
+}
+
+// Close 停止执行器,取消执行中的任务并等待结束,定时触发需要另外停止Manage.Cron
+func (this *TaskRunner) Close() error {
+	this.mu.Lock()
+	this.cancel()
+	this.mu.Unlock()
+	this.wg.Wait()
+	return nil
+}
+
+type taskRunKey struct{}
+
+// TaskRunFrom 获取任务执行器传入的执行记录,用于汇报进度,不是通过执行器执行的返回nil(方法可以正常调用)
+func TaskRunFrom(ctx context.Context) *TaskRun {
+	r, _ := ctx.Value(taskRunKey{}).(*TaskRun)
+	return r
+}
+
+// TaskRun 一次执行
+type TaskRun struct {
+	runner *TaskRunner
+	model  *TaskRunModel
+	mu     sync.Mutex
+	saved  time.Time //上次保存进度的时间
+}
+
+// SetProgress 设置进度,最多5秒保存一次到数据库,允许this为nil
+func (this *TaskRun) SetProgress(done, total int) {
+	if this == nil {
+		return
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.runner.mu.Lock()
+	this.model.Done, this.model.Total = done, total
+	this.runner.mu.Unlock()
+	if this.runner.db != nil && time.Since(this.saved) > time.Second*5 {
+		this.saved = time.Now()
+		if _, err := this.runner.db.ID(this.model.ID).Cols("Done", "Total").Update(this.model); err != nil {
+			logs.Err(err)
+		}
+	}
+}
+
+// ID 执行记录的ID,允许this为nil
+func (this *TaskRun) ID() int64 {
+	if this == nil {
+		return 0
+	}
+	return this.model.ID
+}
+
+func (this *TaskRun) finish(ctx context.Context, err error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.runner.mu.Lock()
+	this.model.End = time.Now().Unix()
+	switch {
+	case err == nil:
+		this.model.Status = TaskSuccess
+	case ctx.Err() != nil:
+		this.model.Status = TaskCanceled
+		this.model.Error = err.Error()
+	default:
+		this.model.Status = TaskFailed
+		this.model.Error = err.Error()
+	}
+	this.runner.mu.Unlock()
+	if this.runner.db != nil {
+		if _, err := this.runner.db.ID(this.model.ID).AllCols().Update(this.model); err != nil {
+			logs.Err(err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package tdx
+
+import (
+	"context"
+	"errors"
+	"github.com/injoyai/tdx/protocol"
+	"github.com/robfig/cron/v3"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testTask struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func (this *testTask) Name() string { return this.name }
+
+func (this *testTask) Run(ctx context.Context, m *Manage) error { return this.run(ctx) }
+
+func TestTaskRunner(t *testing.T) {
+	db, err := newSqliteEngine(filepath.Join(t.TempDir(), "task.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r, err := NewTaskRunner(&Manage{Cron: cron.New(cron.WithSeconds(), cron.WithLocation(protocol.Location))}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//成功,汇报进度
+	err = r.Register(&testTask{name: "ok", run: func(ctx context.Context) error {
+		TaskRunFrom(ctx).SetProgress(10, 10)
+		return nil
+	}}, TaskOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(&testTask{name: "ok"}, TaskOption{}); err == nil {
+		t.Errorf("重复注册应该返回错误")
+	}
+	if err := r.Run(context.Background(), "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := r.Last("ok"); err != nil || !last.Finished() || last.Done != 10 || last.End == 0 {
+		t.Errorf("执行记录错误: %+v %v", last, err)
+	}
+
+	//失败重试,第2次成功
+	n := 0
+	r.Register(&testTask{name: "retry", run: func(ctx context.Context) error {
+		n++
+		if n == 1 {
+			return errors.New("失败")
+		}
+		if n == 2 {
+			panic("崩溃")
+		}
+		return nil
+	}}, TaskOption{Retry: 2, RetryInterval: time.Millisecond})
+	if err := r.Run(context.Background(), "retry"); err != nil || n != 3 {
+		t.Errorf("重试错误: n=%d err=%v", n, err)
+	}
+	ls, err := r.History("retry", 0)
+	if err != nil || len(ls) != 3 || ls[0].Status != TaskSuccess || ls[1].Error != "panic: 崩溃" || ls[2].Status != TaskFailed {
+		t.Errorf("执行历史错误: %v", err)
+	}
+
+	//不能重叠执行,关闭时取消
+	started := make(chan struct{})
+	r.Register(&testTask{name: "block", run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}, TaskOption{Retry: 3})
+	if err := r.Go("block"); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := r.Run(context.Background(), "block"); err != ErrTaskRunning {
+		t.Errorf("应该返回正在执行: %v", err)
+	}
+	if err := r.Run(context.Background(), "none"); err != ErrTaskNotFound {
+		t.Errorf("应该返回任务不存在: %v", err)
+	}
+	r.Close()
+	if last, _ := r.Last("block"); last == nil || last.Status != TaskCanceled || last.Attempt != 1 {
+		t.Errorf("关闭之后应该是取消状态: %+v", last)
+	}
+	if ls, _ := r.History("block", 0); len(ls) != 1 || ls[0].Status != TaskCanceled {
+		t.Errorf("数据库的执行记录错误: %v", ls)
+	}
+	//关闭之后不能再执行
+	if err := r.Run(context.Background(), "ok"); err != ErrTaskClosed {
+		t.Errorf("关闭之后应该返回已关闭: %v", err)
+	}
+	if err := r.Go("ok"); err != ErrTaskClosed {
+		t.Errorf("关闭之后应该返回已关闭: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package tdx
+
+import (
+	"context"
+	"fmt"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx/protocol"
+	"math"
+	"sync"
+	"time"
+)
+
+type TradeTrackerConfig struct {
+	Interval time.Duration //轮询间隔,默认3秒
+	Size     uint16        //每次从最新开始拉取的数量,默认200,没有重叠时会翻倍,最多1800
+}
+
+// NewTradeTracker 实时跟踪今天的分时成交,通过连接池轮询多个代码,只回调新增的成交
+// f 会被多个协程同时调用,同一个代码是按顺序调用的
+func NewTradeTracker(p *Pool, cfg TradeTrackerConfig, f func(code string, ls protocol.Trades)) *TradeTracker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second * 3
+	}
+	if cfg.Size == 0 {
+		cfg.Size = 200
+	}
+	if cfg.Size > 1800 {
+		cfg.Size = 1800
+	}
+	return &TradeTracker{
+		pool:   p,
+		Config: cfg,
+		f:      f,
+		codes:  make(map[string]*tradeTrackerState),
+		now:    protocol.Now,
+	}
+}
+
+// TradeTracker 分时成交跟踪
+// GetMinuteTradeAll 分页读取的时候,读取间隔内产生的成交会导致分页偏移,这里每次从最新的开始读取,
+// 再和上次数据的尾部进行重叠匹配(protocol.Trades.Merge),保证不重复,不遗漏
+type TradeTracker struct {
+	pool   *Pool
+	Config TradeTrackerConfig
+	f      func(code string, ls protocol.Trades)
+	codes  map[string]*tradeTrackerState
+	mu     sync.Mutex
+	now    func() time.Time //当前时间,用于跨天判断
+}
+
+// tradeSource 分时成交的数据来源,一般是*Client
+type tradeSource interface {
+	GetMinuteTrade(code string, start, count uint16) (*protocol.TradeResp, error)
+}
+
+type tradeTrackerState struct {
+	code string
+	date string          //数据的日期,跨天后重新开始
+	tail protocol.Trades //上次数据的尾部,用于重叠匹配
+}
+
+// Add 添加跟踪的代码
+func (this *TradeTracker) Add(codes ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, code := range codes {
+		code = protocol.AddPrefix(code)
+		if _, ok := this.codes[code]; !ok {
+			this.codes[code] = &tradeTrackerState{code: code}
+		}
+	}
+}
+
+// Remove 移除跟踪的代码
+func (this *TradeTracker) Remove(codes ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, code := range codes {
+		delete(this.codes, protocol.AddPrefix(code))
+	}
+}
+
+// Run 按间隔轮询,直到ctx结束
+func (this *TradeTracker) Run(ctx context.Context) error {
+	t := time.NewTicker(this.Config.Interval)
+	defer t.Stop()
+	for {
+		if err := this.Poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Poll 轮询一次所有的代码,并发数量是连接池的数量,单个代码的错误只打印日志
+func (this *TradeTracker) Poll(ctx context.Context) error {
+	this.mu.Lock()
+	states := make([]*tradeTrackerState, 0, len(this.codes))
+	for _, v := range this.codes {
+		states = append(states, v)
+	}
+	this.mu.Unlock()
+
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for _, s := range states {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		wg.Add(1)
+		err := this.pool.Go(func(c *Client) {
+			defer wg.Done()
+			ls, err := this.pull(c, s)
+			if err != nil {
+				logs.Err(err)
+			}
+			if len(ls) > 0 && this.f != nil {
+				this.f(s.code, ls)
+			}
+		})
+		if err != nil {
+			wg.Done()
+			return err
+		}
+	}
+	return nil
+}
+
+// pull 获取代码新增的成交
+func (this *TradeTracker) pull(c tradeSource, s *tradeTrackerState) (protocol.Trades, error) {
+
+	//跨天重新开始
+	if today := this.now().Format("20060102"); s.date != today {
+		s.date = today
+		s.tail = nil
+	}
+
+	//第一次获取全天的数据,或者已有的数据太少无法可靠匹配,按全天数据的序号继续
+	if len(s.tail) < protocol.MinTradeOverlap {
+		return this.pullAll(c, s)
+	}
+
+	//从最新的开始获取,没有重叠则加大数量
+	for size := this.Config.Size; ; size *= 2 {
+		if size > 1800 {
+			size = 1800
+		}
+		resp, err := c.GetMinuteTrade(s.code, 0, size)
+		if err != nil {
+			return nil, err
+		}
+		if add, err := s.tail.Merge(resp.List); err == nil {
+			s.setTail(add)
+			return add, nil
+		}
+		if size >= 1800 || resp.Count < size {
+			break
+		}
+	}
+
+	//单页最大数量也没有重叠(轮询间隔内成交太多),重新获取全天数据
+	all, err := getMinuteTradeAll(c, s.code)
+	if err != nil {
+		return nil, err
+	}
+	if add, err := s.tail.Merge(all); err == nil {
+		s.setTail(add)
+		return add, nil
+	}
+
+	//还是匹配不上(例如服务器数据有变化),按全天数据的序号继续
+	last := s.tail[len(s.tail)-1].Seq
+	s.tail = nil
+	s.setTail(all)
+	if last < len(all) {
+		return all[last:], fmt.Errorf("代码[%s]的分时成交未匹配到重叠数据,按序号%d继续", s.code, last)
+	}
+	return nil, fmt.Errorf("代码[%s]的分时成交未匹配到重叠数据,按序号%d继续", s.code, last)
+}
+
+// pullAll 获取全天的数据(有序号),返回上次序号之后的数据
+func (this *TradeTracker) pullAll(c tradeSource, s *tradeTrackerState) (protocol.Trades, error) {
+	all, err := getMinuteTradeAll(c, s.code)
+	if err != nil {
+		return nil, err
+	}
+	last := 0
+	if len(s.tail) > 0 {
+		last = s.tail[len(s.tail)-1].Seq
+	}
+	s.tail = nil
+	s.setTail(all)
+	if last < len(all) {
+		return all[last:], nil
+	}
+	return nil, nil
+}
+
+// tradeAllOverlap 获取全天数据时,每页和已获取的数据重叠的数量
+// 两页之间新增的成交会让下一页往更早的方向偏移,重叠的部分变多,新增超过一页减去重叠的数量时才会拼接失败
+const tradeAllOverlap = 100
+
+// getMinuteTradeAll 从最新的开始往前分页获取全天的成交,并按序号从1开始编号
+// 返回的是获取第一页时的全部成交,分页期间新增的成交会让偏移量变大,所以每页都和已获取的最早的数据重叠,
+// 用Merge拼接,不像Client.GetMinuteTradeAll按固定偏移量分页,偏移量变化时会重复或者遗漏
+func getMinuteTradeAll(c tradeSource, code string) (protocol.Trades, error) {
+	const size = 1800
+	resp, err := c.GetMinuteTrade(code, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	all := resp.List
+	for len(resp.List) >= size {
+		if len(all)-tradeAllOverlap > math.MaxUint16 {
+			return nil, fmt.Errorf("代码[%s]的分时成交超过了最大偏移量", code)
+		}
+		resp, err = c.GetMinuteTrade(code, uint16(len(all)-tradeAllOverlap), size)
+		if err != nil {
+			return nil, err
+		}
+		add, err := resp.List.Merge(all)
+		if err != nil {
+			return nil, fmt.Errorf("代码[%s]的全天分时成交拼接失败: %w", code, err)
+		}
+		//add是all里面没有和这页重叠的部分
+		all = append(resp.List, add...)
+	}
+	return all.Sequence(1), nil
+}
+
+// setTail 追加新增的数据,只保留尾部用于下次匹配
+func (this *tradeTrackerState) setTail(add protocol.Trades) {
+	ls := append(this.tail, add...)
+	if len(ls) > 20 {
+		ls = ls[len(ls)-20:]
+	}
+	this.tail = append(protocol.Trades(nil), ls...)
+}
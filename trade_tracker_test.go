@@ -0,0 +1,170 @@
+package tdx
+
+import (
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+	"time"
+)
+
+// testTradeSource 模拟服务器的分时成交,list是当天从开盘开始的全部成交
+type testTradeSource struct {
+	list     protocol.Trades
+	minute   int    //GetMinuteTrade的调用次数
+	maxCount uint16 //单次获取的最大数量
+	onFetch  func() //每次获取之前调用,模拟获取期间新增的成交
+}
+
+func (this *testTradeSource) GetMinuteTrade(code string, start, count uint16) (*protocol.TradeResp, error) {
+	this.minute++
+	if this.onFetch != nil {
+		this.onFetch()
+	}
+	if count > this.maxCount {
+		this.maxCount = count
+	}
+	ls := this.list
+	if int(start) > len(ls) {
+		ls = nil
+	} else {
+		ls = ls[:len(ls)-int(start)]
+	}
+	if len(ls) > int(count) {
+		ls = ls[len(ls)-int(count):]
+	}
+	ls = copyTrades(ls)
+	return &protocol.TradeResp{Count: uint16(len(ls)), List: ls}, nil
+}
+
+// add 服务器新增n条成交
+func (this *testTradeSource) add(date time.Time, n int) {
+	for i := 0; i < n; i++ {
+		x := len(this.list)
+		//同一分钟内有多条价格相同的成交
+		this.list = append(this.list, &protocol.Trade{
+			Time:   date.Add(time.Minute * time.Duration(x/20)),
+			Price:  protocol.Price(10000 + x%3),
+			Volume: 1 + x%7,
+		})
+	}
+}
+
+// copyTrades 复制成交,避免修改序号影响服务器的数据
+func copyTrades(ls protocol.Trades) protocol.Trades {
+	result := protocol.Trades(nil)
+	for _, v := range ls {
+		c := *v
+		result = append(result, &c)
+	}
+	return result
+}
+
+func TestTradeTracker_pull(t *testing.T) {
+	day1 := time.Date(2024, 10, 28, 9, 30, 0, 0, protocol.Location)
+	day2 := day1.AddDate(0, 0, 1)
+
+	now := day1
+	tr := NewTradeTracker(nil, TradeTrackerConfig{Size: 10}, nil)
+	tr.now = func() time.Time { return now }
+	src := &testTradeSource{}
+	s := &tradeTrackerState{code: "sz000001"}
+
+	for _, v := range []struct {
+		name   string
+		date   time.Time //服务器数据的日期,变化时模拟跨天
+		add    int       //服务器新增的成交数量
+		change bool      //修改服务器已有的数据,模拟数据变化
+		want   int       //预期返回的新增数量
+		first  int       //预期第一条新增的序号
+		minute int       //预期GetMinuteTrade的调用次数
+		err    bool
+	}{
+		{name: "首次只有2条,获取全天", date: day1, add: 2, want: 2, first: 1, minute: 1},
+		{name: "尾部太少,继续获取全天", date: day1, add: 28, want: 28, first: 3, minute: 1},
+		{name: "没有新增", date: day1, want: 0, minute: 1},
+		{name: "增量合并", date: day1, add: 5, want: 5, first: 31, minute: 1},
+		{name: "重叠太少,翻倍获取", date: day1, add: 9, want: 9, first: 36, minute: 2},
+		{name: "新增超过单次数量,翻倍获取", date: day1, add: 25, want: 25, first: 45, minute: 3},
+		{name: "新增超过单页最大数量,获取全天", date: day1, add: 2000, want: 2000, first: 70, minute: 9 + 2},
+		{name: "数据变化匹配不上,按序号继续", date: day1, add: 3, change: true, want: 3, first: 2070, minute: 9 + 2, err: true},
+		{name: "跨天重新开始", date: day2, add: 50, want: 50, first: 1, minute: 1},
+		{name: "跨天后增量合并", date: day2, add: 4, want: 4, first: 51, minute: 1},
+	} {
+		if !v.date.Equal(now) {
+			now = v.date
+			src.list = nil
+		}
+		if v.change {
+			for _, x := range src.list[len(src.list)-30:] {
+				x.Volume += 100
+			}
+		}
+		src.add(now, v.add)
+		src.minute = 0
+
+		ls, err := tr.pull(src, s)
+		if (err != nil) != v.err {
+			t.Errorf("%s: 错误不符合预期: %v", v.name, err)
+		}
+		if len(ls) != v.want {
+			t.Errorf("%s: 预期新增%d条,得到%d条", v.name, v.want, len(ls))
+			continue
+		}
+		for i, x := range ls {
+			if x.Seq != v.first+i {
+				t.Errorf("%s: 第%d条预期序号%d,得到%d", v.name, i, v.first+i, x.Seq)
+				break
+			}
+			if !x.Equal(src.list[x.Seq-1]) {
+				t.Errorf("%s: 序号%d的数据不一致", v.name, x.Seq)
+				break
+			}
+		}
+		if src.minute != v.minute {
+			t.Errorf("%s: 预期调用%d次,得到%d次", v.name, v.minute, src.minute)
+		}
+		if src.maxCount > 1800 {
+			t.Errorf("%s: 单次获取数量超过1800: %d", v.name, src.maxCount)
+		}
+	}
+}
+
+func Test_getMinuteTradeAll(t *testing.T) {
+	date := time.Date(2024, 10, 28, 9, 30, 0, 0, protocol.Location)
+	for _, v := range []struct {
+		name  string
+		total int //第一次获取时的数量
+		add   int //每次获取之前新增的数量
+		err   bool
+	}{
+		{name: "不足一页", total: 100, add: 0},
+		{name: "多页没有新增", total: 5000, add: 0},
+		{name: "多页每次都有新增", total: 5000, add: 50},
+		{name: "新增超过一页", total: 5000, add: 1800, err: true},
+	} {
+		src := &testTradeSource{}
+		src.add(date, v.total)
+		src.onFetch = func() {
+			if src.minute > 1 {
+				src.add(date, v.add)
+			}
+		}
+		ls, err := getMinuteTradeAll(src, "sz000001")
+		if (err != nil) != v.err {
+			t.Errorf("%s: 错误不符合预期: %v", v.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		//返回的是第一次获取时的全部成交,中间没有重复和遗漏
+		if len(ls) != v.total {
+			t.Errorf("%s: 预期%d条,得到%d条", v.name, v.total, len(ls))
+			continue
+		}
+		for i, x := range ls {
+			if x.Seq != i+1 || !x.Equal(src.list[i]) {
+				t.Errorf("%s: 第%d条不一致", v.name, i)
+				break
+			}
+		}
+	}
+}
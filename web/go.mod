@@ -2,7 +2,10 @@ module web
 
 go 1.23
 
-require github.com/injoyai/tdx v0.0.0
+require (
+	github.com/injoyai/conv v1.2.5
+	github.com/injoyai/tdx v0.0.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -13,7 +16,6 @@ require (
 	github.com/golang/snappy v0.0.4 // indirect
 	github.com/google/uuid v1.5.0 // indirect
 	github.com/injoyai/base v1.2.17 // indirect
-	github.com/injoyai/conv v1.2.5 // indirect
 	github.com/injoyai/ios v1.2.2 // indirect
 	github.com/injoyai/logs v1.0.12 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/injoyai/tdx"
@@ -16,29 +21,70 @@ import (
 
 var client *tdx.Client
 
-func init() {
+// manage 代码,工作日的定时更新和定时拉取k线
+var manage *tdx.Manage
+
+// setup 按配置连接服务器,初始化代码库,注册定时拉取k线
+func setup(cfg *tdx.Config) error {
 	var err error
 	// 连接通达信服务器
-	client, err = tdx.DialDefault(tdx.WithDebug(false))
+	client, err = cfg.Client.DialFunc()(tdx.WithDebug(false))
 	if err != nil {
-		log.Fatalf("连接服务器失败: %v", err)
+		return fmt.Errorf("连接服务器失败: %w", err)
 	}
 	log.Println("成功连接到通达信服务器")
 
-	// 初始化代码缓存
-	if err = os.MkdirAll(tdx.DefaultDatabaseDir, 0755); err != nil {
+	// 初始化代码缓存和定时更新
+	if err = os.MkdirAll(cfg.Storage.Dir, 0755); err != nil {
 		log.Printf("创建数据目录失败: %v", err)
 	}
-	if codes, err := tdx.NewCodesSqlite(client); err != nil {
-		log.Printf("初始化代码库失败: %v", err)
-	} else {
-		tdx.DefaultCodes = codes
-		if err := tdx.DefaultCodes.Update(); err != nil {
-			log.Printf("更新代码库失败: %v", err)
-		} else {
-			log.Printf("已加载股票代码，共 %d 条", len(tdx.DefaultCodes.Map))
-		}
+	manage, err = tdx.NewManageFromConfig(cfg, tdx.WithDebug(false))
+	if err != nil {
+		return fmt.Errorf("初始化代码库失败: %w", err)
+	}
+	tdx.DefaultCodes = manage.Codes
+	log.Printf("已加载股票代码，共 %d 条", tdx.DefaultCodes.Count())
+
+	// 定时拉取k线
+	if err = extend.RegisterPullKline(manage, cfg); err != nil {
+		return fmt.Errorf("注册定时拉取失败: %w", err)
 	}
+	return nil
+}
+
+// auth 接口认证,配置了Token或者用户名密码时生效,健康检查不需要认证
+func auth(cfg tdx.WebConfig, next http.Handler) http.Handler {
+	if cfg.Token == "" && cfg.Username == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/api/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cfg.Token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" {
+				token = r.URL.Query().Get("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if cfg.Username != "" {
+			username, password, ok := r.BasicAuth()
+			if ok && subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Password)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="tdx"`)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		errorResponse(w, "未授权")
+	})
 }
 
 // Response 统一响应结构
@@ -162,7 +208,7 @@ func getQfqKlineDay(code string) (*protocol.KlineResp, error) {
 
 	for i, k := range klines {
 		pk := &protocol.Kline{
-			Time:   time.Unix(k.Date, 0),
+			Time:   time.Unix(k.Date, 0).In(protocol.Location),
 			Open:   k.Open,
 			High:   k.High,
 			Low:    k.Low,
@@ -369,39 +415,33 @@ func handleSearchCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	keywordUpper := strings.ToUpper(keyword)
-	results := []map[string]string{}
-	seen := map[string]struct{}{}
-
-	codeModels, err := getAllCodeModels()
-	if err != nil {
-		errorResponse(w, "搜索失败: "+err.Error())
-		return
+	//types限制品种,多个用逗号分隔,例stock,etf,默认股票
+	kinds := []string{protocol.KindStock}
+	if types := r.URL.Query().Get("types"); types != "" {
+		kinds = strings.Split(types, ",")
 	}
 
-	for _, model := range codeModels {
-		fullCode := model.FullCode()
-		if !protocol.IsStock(fullCode) {
-			continue
-		}
-		if _, ok := seen[model.Code]; ok {
-			continue
-		}
-
-		codeUpper := strings.ToUpper(model.Code)
-		nameUpper := strings.ToUpper(model.Name)
-		if strings.Contains(codeUpper, keywordUpper) || strings.Contains(nameUpper, keywordUpper) {
-			results = append(results, map[string]string{
-				"code":     model.Code,
-				"name":     model.Name,
-				"exchange": strings.ToLower(model.Exchange),
-			})
-			seen[model.Code] = struct{}{}
+	var search func(keyword string, limit int, kinds ...string) []*tdx.CodeModel
+	if tdx.DefaultCodes != nil {
+		search = tdx.DefaultCodes.Search
+	} else {
+		//代码库初始化失败时,临时从服务器获取代码创建索引
+		codeModels, err := getAllCodeModels()
+		if err != nil {
+			errorResponse(w, "搜索失败: "+err.Error())
+			return
 		}
+		search = tdx.NewSearchIndex(tdx.SearchConfig{}, codeModels).Search
+	}
 
-		if len(results) >= 50 {
-			break
-		}
+	results := []map[string]string{}
+	for _, model := range search(keyword, 50, kinds...) {
+		results = append(results, map[string]string{
+			"code":     model.Code,
+			"name":     model.Name,
+			"exchange": strings.ToLower(model.Exchange),
+			"kind":     protocol.CodeKind(model.FullCode()),
+		})
 	}
 
 	successResponse(w, results)
@@ -448,13 +488,14 @@ func handleGetStockInfo(w http.ResponseWriter, r *http.Request) {
 	successResponse(w, result)
 }
 
+// splitCodes 分割多个代码,并统一成带交易所前缀的格式(例000001.SZ会变成sz000001),格式见tdx.Codes.ParseSymbol
 func splitCodes(param string) []string {
 	parts := strings.Split(param, ",")
 	result := make([]string, 0, len(parts))
 	for _, p := range parts {
 		code := strings.TrimSpace(p)
 		if code != "" {
-			result = append(result, code)
+			result = append(result, tdx.DefaultCodes.AddExchange(code))
 		}
 	}
 	return result
@@ -466,7 +507,7 @@ func getMinuteWithFallback(code, date string) (*protocol.MinuteResp, string, err
 		return resp, date, err
 	}
 
-	today := time.Now()
+	today := protocol.Now()
 	const maxLookback = 10
 
 	var lastResp *protocol.MinuteResp
@@ -499,6 +540,37 @@ func getMinuteWithFallback(code, date string) (*protocol.MinuteResp, string, err
 }
 
 func main() {
+	configFile := flag.String("config", os.Getenv("TDX_CONFIG"), "配置文件(yaml,toml),也可以使用环境变量TDX_CONFIG")
+	check := flag.Bool("check", false, "只校验配置文件,不启动服务")
+	flag.Parse()
+
+	cfg, err := tdx.LoadConfig(*configFile)
+	if err == nil && cfg.Pull.Enabled() {
+		err = extend.CheckKlineTables(cfg.Pull.Tables)
+	}
+	if *check {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "配置错误:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("配置正确")
+		return
+	}
+	if err != nil {
+		log.Fatalf("配置错误: %v", err)
+	}
+	if err = setup(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	// 收到退出信号后,等待处理中的请求结束,再关闭定时任务,数据库和客户端(见shutdown)
+	// manage不使用信号的ctx,避免和处理中的请求同时关闭
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err = manage.Start(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
 	// 静态文件服务
 	http.Handle("/", http.FileServer(http.Dir("./static")))
 
@@ -517,7 +589,29 @@ func main() {
 	http.HandleFunc("/api/server-status", handleGetServerStatus)
 	http.HandleFunc("/api/health", handleHealthCheck)
 
-	port := ":8080"
-	log.Printf("服务启动成功，访问 http://localhost%s\n", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	srv := &http.Server{Addr: cfg.Web.Listen, Handler: auth(cfg.Web, http.DefaultServeMux)}
+	go func() {
+		log.Printf("服务启动成功，监听 %s\n", cfg.Web.Listen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("正在关闭服务...")
+	shutdown(srv, 30*time.Second)
+	log.Println("服务已关闭")
+}
+
+// shutdown 优雅关闭,最多等待timeout
+func shutdown(srv *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("关闭HTTP服务失败: %v", err)
+	}
+	if err := manage.Close(ctx); err != nil {
+		log.Printf("关闭定时任务和数据库失败: %v", err)
+	}
+	client.CloseAll()
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/injoyai/tdx"
 	"github.com/injoyai/tdx/protocol"
@@ -122,6 +123,12 @@ func handleGetKlineHistory(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	//指定了日期范围时按范围查询,只拉取需要的分页
+	if startDate, endDate := r.URL.Query().Get("start_date"), r.URL.Query().Get("end_date"); startDate != "" || endDate != "" {
+		handleGetKlineRange(w, code, klineType, startDate, endDate, limitStr != "", limit)
+		return
+	}
+
 	var resp *protocol.KlineResp
 	var err error
 
@@ -178,6 +185,59 @@ func handleGetKlineHistory(w http.ResponseWriter, r *http.Request) {
 	successResponse(w, resp)
 }
 
+// klineRangeTypes kline-history按日期范围查询时支持的k线类型
+var klineRangeTypes = map[string]protocol.KlineType{
+	"minute1":  protocol.TypeKlineMinute,
+	"minute5":  protocol.TypeKline5Minute,
+	"minute15": protocol.TypeKline15Minute,
+	"minute30": protocol.TypeKline30Minute,
+	"hour":     protocol.TypeKlineHour,
+	"day":      protocol.TypeKlineDay,
+	"week":     protocol.TypeKlineWeek,
+	"month":    protocol.TypeKlineMonth,
+}
+
+// handleGetKlineRange 按日期范围[startDate,endDate]获取k线,日期格式YYYYMMDD,为空不限制,返回通达信的不复权数据
+// 通过工作日估算偏移量(tdx.Client.GetKlineRange),不需要从最新的数据一直拉取到开始日期
+func handleGetKlineRange(w http.ResponseWriter, code, klineType, startDate, endDate string, hasLimit bool, limit uint16) {
+	if klineType == "" {
+		klineType = "day"
+	}
+	Type, ok := klineRangeTypes[klineType]
+	if !ok {
+		errorResponse(w, "未知的K线类型: "+klineType)
+		return
+	}
+	var from, to time.Time
+	var err error
+	if startDate != "" {
+		if from, err = time.ParseInLocation("20060102", startDate, protocol.Location); err != nil {
+			errorResponse(w, "开始日期格式错误,例20241001")
+			return
+		}
+	}
+	if endDate != "" {
+		if to, err = time.ParseInLocation("20060102", endDate, protocol.Location); err != nil {
+			errorResponse(w, "结束日期格式错误,例20241101")
+			return
+		}
+		//分钟线的时间是当天的具体时间,包含结束日期当天
+		to = to.Add(time.Hour*24 - time.Second)
+	}
+
+	resp, err := client.GetKlineRange(Type, tdx.DefaultCodes.AddExchange(code), from, to, manage.Workday)
+	if err != nil {
+		errorResponse(w, fmt.Sprintf("获取K线失败: %v", err))
+		return
+	}
+	//传了limit时只返回最近limit条
+	if hasLimit && len(resp.List) > int(limit) {
+		resp.List = resp.List[len(resp.List)-int(limit):]
+		resp.Count = limit
+	}
+	successResponse(w, resp)
+}
+
 // 获取指数数据
 func handleGetIndex(w http.ResponseWriter, r *http.Request) {
 	code := r.URL.Query().Get("code")
@@ -200,38 +260,17 @@ func handleGetIndex(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var resp *protocol.KlineResp
-	var err error
-
-	// 根据类型选择对应的指数接口
-	switch klineType {
-	case "minute1":
-		resp, err = client.GetIndex(protocol.TypeKlineMinute, code, 0, limit)
-	case "minute5":
-		resp, err = client.GetIndex(protocol.TypeKline5Minute, code, 0, limit)
-	case "minute15":
-		resp, err = client.GetIndex(protocol.TypeKline15Minute, code, 0, limit)
-	case "minute30":
-		resp, err = client.GetIndex(protocol.TypeKline30Minute, code, 0, limit)
-	case "hour":
-		resp, err = client.GetIndex(protocol.TypeKline60Minute, code, 0, limit)
-	case "week":
-		resp, err = client.GetIndexWeekAll(code)
-		if resp != nil && len(resp.List) > int(limit) {
-			resp.List = resp.List[:limit]
-			resp.Count = limit
-		}
-	case "month":
-		resp, err = client.GetIndexMonthAll(code)
-		if resp != nil && len(resp.List) > int(limit) {
-			resp.List = resp.List[:limit]
-			resp.Count = limit
-		}
-	case "day":
-		fallthrough
-	default:
-		resp, err = client.GetIndexDay(code, 0, limit)
+	// 根据类型获取对应的指数K线,未知类型按日K线处理
+	Type, err := protocol.ParseKlineType(klineType)
+	if err != nil {
+		Type = protocol.TypeKlineDay
 	}
+	resp, err := client.GetBars(r.Context(), tdx.BarsRequest{
+		Code:  code,
+		Type:  Type,
+		Kind:  protocol.KindIndex,
+		Limit: int(limit),
+	})
 
 	if err != nil {
 		errorResponse(w, fmt.Sprintf("获取指数数据失败: %v", err))
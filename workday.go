@@ -9,11 +9,11 @@ import (
 	"github.com/injoyai/ios/client"
 	"github.com/injoyai/logs"
 	"github.com/injoyai/tdx/protocol"
-	"github.com/robfig/cron/v3"
-	"os"
-	"path/filepath"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
-	"xorm.io/core"
 	"xorm.io/xorm"
 )
 
@@ -26,71 +26,88 @@ func DialWorkday(op ...client.Option) (*Workday, error) {
 }
 
 func NewWorkdayMysql(c *Client, dsn string) (*Workday, error) {
-
-	//连接数据库
-	db, err := xorm.NewEngine("mysql", dsn)
+	store, err := NewCalendarStoreMysql(dsn)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMapper(core.SameMapper{})
-
-	return NewWorkday(c, db)
+	return NewWorkdayStore(c, store)
 }
 
 func NewWorkdaySqlite(c *Client, filenames ...string) (*Workday, error) {
-
-	defaultFilename := filepath.Join(DefaultDatabaseDir, "workday.db")
-	filename := conv.Default(defaultFilename, filenames...)
-
-	//如果文件夹不存在就创建
-	dir, _ := filepath.Split(filename)
-	_ = os.MkdirAll(dir, 0777)
-
-	//连接数据库
-	db, err := xorm.NewEngine("sqlite", filename)
+	store, err := NewCalendarStoreSqlite(conv.Default("", filenames...))
 	if err != nil {
 		return nil, err
 	}
-	db.SetMapper(core.SameMapper{})
-	db.DB().SetMaxOpenConns(1)
-
-	return NewWorkday(c, db)
+	return NewWorkdayStore(c, store)
 }
 
 func NewWorkday(c *Client, db *xorm.Engine) (*Workday, error) {
-	if err := db.Sync2(new(WorkdayModel)); err != nil {
+	store, err := NewCalendarStoreXorm(db)
+	if err != nil {
 		return nil, err
 	}
+	return NewWorkdayStore(c, store)
+}
 
+// NewWorkdayStore 使用自定义的存储,例如NewCalendarStoreMemory,NewCalendarStoreJSON
+// 只加载数据,不会定时更新,定时更新见Refresher.AddWorkday
+func NewWorkdayStore(c *Client, store CalendarStore) (*Workday, error) {
 	w := &Workday{
 		Client: c,
-		db:     db,
+		store:  store,
 		cache:  maps.NewBit(),
 	}
-	//设置定时器,每天早上9点更新数据,8点多获取不到今天的数据
-	task := cron.New(cron.WithSeconds())
-	task.AddFunc("0 0 9 * * *", func() {
-		for i := 0; i < 3; i++ {
-			err := w.Update()
-			if err == nil {
-				return
-			}
-			logs.Err(err)
-			<-time.After(time.Minute * 5)
-		}
-	})
-	task.Start()
 	return w, w.Update()
 }
 
 type Workday struct {
 	*Client
-	db    *xorm.Engine
+	store CalendarStore
 	cache maps.Bit
+	last  atomic.Int64 //已知的最后一个工作日(workdayKey),之后的日期没有数据
+
+	updateMu sync.Mutex //更新互斥,关闭时等待更新结束
+
+	holidays   *Holidays                  //休市安排,为空使用DefaultHolidays
+	onConflict []func(c CalendarConflict) //实际的交易日和休市安排不一致的回调
+	mu         sync.RWMutex               //休市安排和回调的锁
+}
+
+// set 设置工作日
+func (this *Workday) set(t time.Time) {
+	key := workdayKey(t)
+	this.cache.Set(key, true)
+	for {
+		last := this.last.Load()
+		if int64(key) <= last || this.last.CompareAndSwap(last, int64(key)) {
+			return
+		}
+	}
 }
 
-// Update 更新
+// Close 等待执行中的更新结束,关闭存储(实现了io.Closer的,例如数据库)
+// 不会关闭客户端,客户端一般和Codes等共用,需要另外关闭
+func (this *Workday) Close() error {
+	this.updateMu.Lock()
+	defer this.updateMu.Unlock()
+	if c, ok := this.store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Update 更新,并检查实际的交易日和休市安排是否一致
 func (this *Workday) Update() error {
+	this.updateMu.Lock()
+	defer this.updateMu.Unlock()
+	if err := this.update(); err != nil {
+		return err
+	}
+	this.alert(this.Check(time.Time{}, time.Now()))
+	return nil
+}
+
+func (this *Workday) update() error {
 
 	if this.Client == nil {
 		return errors.New("client is nil")
@@ -100,31 +117,35 @@ func (this *Workday) Update() error {
 	//判断日K线是否拉取过
 
 	//获取全部工作日
-	all := []*WorkdayModel(nil)
-	if err := this.db.Find(&all); err != nil {
+	all, err := this.store.LoadWorkdays()
+	if err != nil {
 		return err
 	}
 	var lastWorkday = &WorkdayModel{}
-	if len(all) > 0 {
-		lastWorkday = all[len(all)-1]
-	}
 	for _, v := range all {
-		this.cache.Set(uint64(v.Unix), true)
+		if v.Date > lastWorkday.Date {
+			lastWorkday = v
+		}
+		//按日期计算缓存,兼容之前按服务器本地时区保存的时间戳
+		t, err := time.ParseInLocation("20060102", v.Date, protocol.Location)
+		if err != nil {
+			t = time.Unix(v.Unix, 0)
+		}
+		this.set(t)
 	}
 
-	now := time.Now()
-	if lastWorkday.Unix < IntegerDay(now).Unix() {
+	if lastWorkday.Date < IntegerDay(time.Now()).Format("20060102") {
 		resp, err := this.Client.GetIndexDayAll("sh000001")
 		if err != nil {
 			logs.Err(err)
 			return err
 		}
 
-		inserts := []any(nil)
+		inserts := []*WorkdayModel(nil)
 		for _, v := range resp.List {
-			if unix := v.Time.Unix(); unix > lastWorkday.Unix {
-				inserts = append(inserts, &WorkdayModel{Unix: unix, Date: v.Time.Format("20060102")})
-				this.cache.Set(uint64(unix), true)
+			if date := v.Time.In(protocol.Location).Format("20060102"); date > lastWorkday.Date {
+				inserts = append(inserts, &WorkdayModel{Unix: v.Time.Unix(), Date: date})
+				this.set(v.Time)
 			}
 		}
 
@@ -132,17 +153,31 @@ func (this *Workday) Update() error {
 			return nil
 		}
 
-		_, err = this.db.Insert(inserts)
-		return err
+		return this.store.AddWorkdays(inserts)
 
 	}
 
 	return nil
 }
 
-// Is 是否是工作日
+// Is 是否是工作日(交易日),按交易所时区的日期判断
+// 已经拉取过的日期按实际的交易日判断,之后的日期(未来)按休市安排判断,休市安排没有公布的年份按周一到周五估算
+// 当天在收盘之前也会返回true,只遍历已经收盘的交易日见Range
 func (this *Workday) Is(t time.Time) bool {
-	return this.cache.Get(uint64(IntegerDay(t).Add(time.Hour * 15).Unix()))
+	key := workdayKey(t)
+	if int64(key) > this.last.Load() {
+		if trading, known := this.Holidays().Is(t); known {
+			return trading
+		}
+		w := IntegerDay(t).Weekday()
+		return w != time.Saturday && w != time.Sunday
+	}
+	return this.cache.Get(key)
+}
+
+// closed 交易日t是否已经收盘(15:00),当天收盘之前和未来的日期返回false
+func closed(t time.Time) bool {
+	return int64(workdayKey(t)) <= protocol.Now().Unix()
 }
 
 // TodayIs 今天是否是工作日
@@ -153,18 +188,16 @@ func (this *Workday) TodayIs() bool {
 // RangeYear 遍历一年的所有工作日
 func (this *Workday) RangeYear(year int, f func(t time.Time) bool) {
 	this.Range(
-		time.Date(year, 1, 1, 0, 0, 0, 0, time.Local),
-		time.Date(year, 12, 31, 0, 0, 0, 0, time.Local),
+		time.Date(year, 1, 1, 0, 0, 0, 0, protocol.Location),
+		time.Date(year+1, 1, 1, 0, 0, 0, 0, protocol.Location),
 		f,
 	)
 }
 
-// Range 遍历指定范围的工作日,推荐start带上时间15:00,这样当天小于15点不会触发
+// Range 遍历指定范围已经收盘的工作日,当天15点之前和未来的日期不会遍历,例如用于获取历史数据
 func (this *Workday) Range(start, end time.Time, f func(t time.Time) bool) {
 	start = conv.Select(start.Before(protocol.ExchangeEstablish), protocol.ExchangeEstablish, start)
-	//now := IntegerDay(time.Now())
-	//end = conv.Select(end.After(now), now, end).Add(1)
-	for ; start.Before(end); start = start.Add(time.Hour * 24) {
+	for ; start.Before(end) && closed(start); start = start.Add(time.Hour * 24) {
 		if this.Is(start) {
 			if !f(start) {
 				return
@@ -173,11 +206,11 @@ func (this *Workday) Range(start, end time.Time, f func(t time.Time) bool) {
 	}
 }
 
-// RangeDesc 倒序遍历工作日,从今天-1990年12月19日(上海交易所成立时间)
+// RangeDesc 倒序遍历已经收盘的工作日,从今天(没有收盘从昨天)-1990年12月19日(上海交易所成立时间)
 func (this *Workday) RangeDesc(f func(t time.Time) bool) {
 	t := IntegerDay(time.Now())
-	for ; t.After(time.Date(1990, 12, 18, 0, 0, 0, 0, time.Local)); t = t.Add(-time.Hour * 24) {
-		if this.Is(t) {
+	for ; t.After(time.Date(1990, 12, 18, 0, 0, 0, 0, protocol.Location)); t = t.Add(-time.Hour * 24) {
+		if this.Is(t) && closed(t) {
 			if !f(t) {
 				return
 			}
@@ -185,6 +218,34 @@ func (this *Workday) RangeDesc(f func(t time.Time) bool) {
 	}
 }
 
+// estimateKlineOffset 估算时间t之后的k线数量,即t对应的k线偏移量,用于按时间范围查询k线
+// 只统计t之后到昨天的完整工作日,所以一般是偏小的,停牌的情况下会偏大,需要调用方修正
+func (this *Workday) estimateKlineOffset(Type protocol.KlineType, t time.Time) uint16 {
+	if t.IsZero() {
+		return 0
+	}
+	var perDay int
+	switch {
+	case Type.IsMinute():
+		perDay = int(time.Hour * 4 / Type.Duration())
+	case Type == protocol.TypeKlineDay || Type == protocol.TypeKlineDay2:
+		perDay = 1
+	default:
+		//周线月线等数量比较少,直接从最新的开始拉取
+		return 0
+	}
+	days := 0
+	this.Range(IntegerDay(t).Add(time.Hour*24), IntegerDay(time.Now()), func(time.Time) bool {
+		days++
+		return true
+	})
+	n := days * perDay
+	if n > math.MaxUint16-1600 {
+		n = math.MaxUint16 - 1600
+	}
+	return uint16(n)
+}
+
 // WorkdayModel 工作日
 type WorkdayModel struct {
 	ID   int64  `json:"id"`   //主键
@@ -196,7 +257,84 @@ func (this *WorkdayModel) TableName() string {
 	return "workday"
 }
 
+// IntegerDay 交易所时区(protocol.Location)当天的零点
 func IntegerDay(t time.Time) time.Time {
-	year, month, day := t.Date()
-	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	year, month, day := t.In(protocol.Location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, protocol.Location)
+}
+
+// workdayKey 工作日的缓存键,交易所时区当天15:00的时间戳,和日k线的时间一致
+func workdayKey(t time.Time) uint64 {
+	return uint64(IntegerDay(t).Add(time.Hour * 15).Unix())
+}
+
+// SetHolidays 设置休市安排,例如加载了本地文件的NewHolidays
+func (this *Workday) SetHolidays(h *Holidays) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.holidays = h
+}
+
+// Holidays 休市安排,没有设置返回DefaultHolidays
+func (this *Workday) Holidays() *Holidays {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	if this.holidays == nil {
+		return DefaultHolidays
+	}
+	return this.holidays
+}
+
+// OnConflict 实际的交易日和休市安排不一致时回调,例如休市安排填错了或者临时休市,没有设置回调时打印错误日志
+func (this *Workday) OnConflict(f func(c CalendarConflict)) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.onConflict = append(this.onConflict, f)
+}
+
+// Check 检查start到end之间实际的交易日和休市安排是否一致,只检查已经公布休市安排的年份和已经拉取过的日期
+func (this *Workday) Check(start, end time.Time) []CalendarConflict {
+	last := this.last.Load()
+	if last == 0 {
+		return nil
+	}
+	if lastDay := time.Unix(last, 0); end.After(lastDay) {
+		end = lastDay
+	}
+	holidays := this.Holidays()
+	years := holidays.Years()
+	if len(years) == 0 {
+		return nil
+	}
+	if first := time.Date(years[0], 1, 1, 0, 0, 0, 0, protocol.Location); start.Before(first) {
+		start = first
+	}
+	ls := []CalendarConflict(nil)
+	for t := IntegerDay(start); !t.After(end); t = IntegerDay(t.Add(time.Hour * 36)) {
+		trading, known := holidays.Is(t)
+		if !known {
+			continue
+		}
+		if observed := this.cache.Get(workdayKey(t)); observed != trading {
+			ls = append(ls, CalendarConflict{Date: t, Observed: observed})
+		}
+	}
+	return ls
+}
+
+func (this *Workday) alert(ls []CalendarConflict) {
+	if len(ls) == 0 {
+		return
+	}
+	this.mu.RLock()
+	fs := this.onConflict
+	this.mu.RUnlock()
+	for _, c := range ls {
+		if len(fs) == 0 {
+			logs.Err(c.String())
+		}
+		for _, f := range fs {
+			f(c)
+		}
+	}
 }
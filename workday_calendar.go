@@ -0,0 +1,189 @@
+package tdx
+
+import (
+	"github.com/injoyai/tdx/protocol"
+	"time"
+)
+
+const (
+	SessionAuction    = "auction"    //开盘集合竞价 9:15-9:25
+	SessionMorning    = "morning"    //上午连续竞价 9:30-11:30
+	SessionLunch      = "lunch"      //午休 11:30-13:00
+	SessionAfternoon  = "afternoon"  //下午连续竞价和收盘集合竞价 13:00-15:00
+	SessionAfterHours = "afterHours" //盘后固定价格交易 15:05-15:30,只有科创板,创业板和北交所
+)
+
+// Session 交易时段
+type Session struct {
+	Type   string    //时段类型,例SessionMorning
+	Start  time.Time //开始时间
+	End    time.Time //结束时间
+	Boards []string  //适用的板块,为空表示全部,例盘后固定价格交易是protocol.BoardSTAR等
+}
+
+// Trading 是否是可以交易(委托)的时段,午休不是
+func (this Session) Trading() bool {
+	return this.Type != SessionLunch
+}
+
+// Contains 时间t是否在时段内,包含开始不包含结束
+func (this Session) Contains(t time.Time) bool {
+	return !t.Before(this.Start) && t.Before(this.End)
+}
+
+// Match 时段是否适用于该代码
+func (this Session) Match(code string) bool {
+	if len(this.Boards) == 0 {
+		return true
+	}
+	board := protocol.CodeBoard(code)
+	for _, v := range this.Boards {
+		if v == board {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionTemplates 交易时段,距离当天零点的分钟数
+var sessionTemplates = []struct {
+	Type       string
+	Start, End int
+	Boards     []string
+}{
+	{SessionAuction, 9*60 + 15, 9*60 + 25, nil},
+	{SessionMorning, 9*60 + 30, 11*60 + 30, nil},
+	{SessionLunch, 11*60 + 30, 13 * 60, nil},
+	{SessionAfternoon, 13 * 60, 15 * 60, nil},
+	{SessionAfterHours, 15*60 + 5, 15*60 + 30, []string{protocol.BoardSTAR, protocol.BoardChiNext, protocol.BoardBSE}},
+}
+
+// Next 时间t之后(不包含当天)的下一个交易日,返回交易所时区的零点
+func (this *Workday) Next(t time.Time) time.Time {
+	t = IntegerDay(t)
+	//最长的休市(春节,国庆)不会超过2周,未知数据按工作日估算,这里限制1年防止死循环
+	for i := 0; i < 366; i++ {
+		t = IntegerDay(t.Add(time.Hour * 36))
+		if this.Is(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Prev 时间t之前(不包含当天)的上一个交易日,返回交易所时区的零点,没有返回零值
+func (this *Workday) Prev(t time.Time) time.Time {
+	t = IntegerDay(t)
+	for t.After(protocol.ExchangeEstablish) {
+		t = IntegerDay(t.Add(-time.Hour * 12))
+		if this.Is(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// AddTradingDays 时间t加上n个交易日,n<0往前,返回交易所时区的零点
+// n=0时,t是交易日返回当天,否则返回下一个交易日
+func (this *Workday) AddTradingDays(t time.Time, n int) time.Time {
+	if n == 0 {
+		if this.Is(t) {
+			return IntegerDay(t)
+		}
+		return this.Next(t)
+	}
+	for ; n > 0 && !t.IsZero(); n-- {
+		t = this.Next(t)
+	}
+	for ; n < 0 && !t.IsZero(); n++ {
+		t = this.Prev(t)
+	}
+	return t
+}
+
+// CountBetween 日期a到日期b之间的交易日数量,包含a和b当天,a在b之后返回负数
+func (this *Workday) CountBetween(a, b time.Time) int {
+	sign := 1
+	a, b = IntegerDay(a), IntegerDay(b)
+	if a.After(b) {
+		a, b, sign = b, a, -1
+	}
+	n := 0
+	for ; !a.After(b); a = IntegerDay(a.Add(time.Hour * 36)) {
+		if this.Is(a) {
+			n++
+		}
+	}
+	return n * sign
+}
+
+// Sessions 某天的交易时段,按时间排序,不是交易日返回nil
+// 包含只适用于部分板块的时段(盘后固定价格交易),可以用Session.Match过滤
+func (this *Workday) Sessions(date time.Time) []Session {
+	if !this.Is(date) {
+		return nil
+	}
+	return daySessions(date)
+}
+
+func daySessions(date time.Time) []Session {
+	day := IntegerDay(date)
+	ls := make([]Session, 0, len(sessionTemplates))
+	for _, v := range sessionTemplates {
+		ls = append(ls, Session{
+			Type:   v.Type,
+			Start:  day.Add(time.Duration(v.Start) * time.Minute),
+			End:    day.Add(time.Duration(v.End) * time.Minute),
+			Boards: v.Boards,
+		})
+	}
+	return ls
+}
+
+// IsTradingTime 是否是交易时间(开盘集合竞价,上午和下午),不包含午休和盘后固定价格交易
+// 传入代码时按代码的板块判断,包含适用的盘后固定价格交易
+func (this *Workday) IsTradingTime(t time.Time, code ...string) bool {
+	for _, v := range this.Sessions(t) {
+		if !v.Trading() || !v.Contains(t) {
+			continue
+		}
+		if len(code) == 0 {
+			if len(v.Boards) == 0 {
+				return true
+			}
+			continue
+		}
+		if v.Match(code[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen 下一次连续竞价开始的时间(9:30或者13:00),t在连续竞价时段内则返回t
+func (this *Workday) NextOpen(t time.Time) time.Time {
+	for day := t; !day.IsZero(); day = this.Next(day) {
+		for _, v := range this.Sessions(day) {
+			if v.Type != SessionMorning && v.Type != SessionAfternoon {
+				continue
+			}
+			if v.Contains(t) {
+				return t
+			}
+			if v.Start.After(t) {
+				return v.Start
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// LastClose t时刻最近一个已经收盘(15:00)的交易日,返回交易所时区的零点
+// 例如作为收盘后批量任务的目标交易日,任务跨过零点也还是同一个交易日
+func (this *Workday) LastClose(t time.Time) time.Time {
+	t = t.In(protocol.Location)
+	if this.Is(t) && t.Hour() >= 15 {
+		return IntegerDay(t)
+	}
+	return this.Prev(t)
+}
@@ -0,0 +1,120 @@
+package tdx
+
+import (
+	"github.com/injoyai/base/maps"
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+	"time"
+)
+
+func TestWorkday_Calendar(t *testing.T) {
+	date := func(s string) time.Time {
+		t, _ := time.ParseInLocation("20060102 15:04", s, protocol.Location)
+		return t
+	}
+
+	//2024年国庆节,10月1日-7日休市
+	w := &Workday{cache: maps.NewBit()}
+	for _, v := range []string{"20240926", "20240927", "20240930", "20241008", "20241009"} {
+		w.set(date(v + " 15:00"))
+	}
+
+	for _, v := range []struct {
+		name      string
+		got, want time.Time
+	}{
+		{"Next", w.Next(date("20240930 10:00")), date("20241008 00:00")},
+		{"Next未来按工作日估算", w.Next(date("20241011 00:00")), date("20241014 00:00")},
+		{"Prev", w.Prev(date("20241008 10:00")), date("20240930 00:00")},
+		{"Prev非交易日", w.Prev(date("20241005 00:00")), date("20240930 00:00")},
+		{"AddTradingDays", w.AddTradingDays(date("20240927 00:00"), 2), date("20241008 00:00")},
+		{"AddTradingDays负数", w.AddTradingDays(date("20241008 00:00"), -2), date("20240927 00:00")},
+		{"AddTradingDays0", w.AddTradingDays(date("20241005 00:00"), 0), date("20241008 00:00")},
+		{"NextOpen收盘后", w.NextOpen(date("20240930 15:00")), date("20241008 09:30")},
+		{"NextOpen午休", w.NextOpen(date("20241008 12:00")), date("20241008 13:00")},
+		{"NextOpen交易中", w.NextOpen(date("20241008 10:00")), date("20241008 10:00")},
+		{"NextOpen集合竞价", w.NextOpen(date("20241008 09:20")), date("20241008 09:30")},
+		{"LastClose收盘后", w.LastClose(date("20240930 15:10")), date("20240930 00:00")},
+		{"LastClose过了零点", w.LastClose(date("20241001 01:00")), date("20240930 00:00")},
+		{"LastClose收盘前", w.LastClose(date("20241008 10:00")), date("20240930 00:00")},
+		{"LastClose休市", w.LastClose(date("20241005 20:00")), date("20240930 00:00")},
+	} {
+		if !v.got.Equal(v.want) {
+			t.Errorf("%s: 期望 %s, 得到 %s", v.name, v.want, v.got)
+		}
+	}
+
+	if n := w.CountBetween(date("20240927 00:00"), date("20241009 00:00")); n != 4 {
+		t.Errorf("CountBetween: %d", n)
+	}
+	if n := w.CountBetween(date("20241009 00:00"), date("20240927 00:00")); n != -4 {
+		t.Errorf("CountBetween倒序: %d", n)
+	}
+
+	if ls := w.Sessions(date("20241001 00:00")); ls != nil {
+		t.Errorf("休市日不应该有交易时段")
+	}
+	if ls := w.Sessions(date("20241008 00:00")); len(ls) != 5 || ls[0].Type != SessionAuction || !ls[1].Start.Equal(date("20241008 09:30")) {
+		t.Errorf("交易时段错误: %v", ls)
+	}
+
+	for _, v := range []struct {
+		t    string
+		code string
+		want bool
+	}{
+		{"20241008 09:20", "", true},
+		{"20241008 09:27", "", false},
+		{"20241008 10:00", "", true},
+		{"20241008 11:30", "", false},
+		{"20241008 14:59", "", true},
+		{"20241008 15:10", "", false},
+		{"20241008 15:10", "sh688001", true},
+		{"20241008 15:10", "sz300750", true},
+		{"20241008 15:10", "sh600000", false},
+		{"20241001 10:00", "", false},
+	} {
+		code := []string(nil)
+		if v.code != "" {
+			code = append(code, v.code)
+		}
+		if got := w.IsTradingTime(date(v.t), code...); got != v.want {
+			t.Errorf("IsTradingTime(%s %s): 期望 %v", v.t, v.code, v.want)
+		}
+	}
+}
+
+func TestWorkday_Range(t *testing.T) {
+	today := IntegerDay(time.Now())
+	w := &Workday{cache: maps.NewBit()}
+	for i := 3; i >= 0; i-- {
+		w.set(today.AddDate(0, 0, -i))
+	}
+
+	//当天收盘之前不遍历,未来的日期也不遍历
+	days := []time.Time(nil)
+	w.Range(today.AddDate(0, 0, -3), today.AddDate(0, 0, 3), func(t time.Time) bool {
+		days = append(days, t)
+		return true
+	})
+	want := 3
+	if !time.Now().Before(today.Add(time.Hour * 15)) {
+		want = 4
+	}
+	if len(days) != want || !days[0].Equal(today.AddDate(0, 0, -3)) {
+		t.Errorf("预期遍历%d天,得到%v", want, days)
+	}
+	//Is不区分是否收盘
+	if !w.Is(time.Now()) {
+		t.Error("今天预期是工作日")
+	}
+
+	//已知数据之后,休市安排没有公布的年份,Is和Next都按周一到周五估算
+	monday := time.Date(2100, 1, 4, 0, 0, 0, 0, protocol.Location)
+	if !w.Is(monday) || w.Is(monday.AddDate(0, 0, -1)) {
+		t.Error("2100年预期按周一到周五估算")
+	}
+	if next := w.Next(monday.AddDate(0, 0, -3)); !next.Equal(monday) {
+		t.Errorf("Next预期%s,得到%s", monday, next)
+	}
+}
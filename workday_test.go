@@ -0,0 +1,39 @@
+package tdx
+
+import (
+	"github.com/injoyai/base/maps"
+	"github.com/injoyai/tdx/protocol"
+	"testing"
+	"time"
+)
+
+func TestWorkday_Is_Location(t *testing.T) {
+	old := time.Local
+	defer func() { time.Local = old }()
+
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("UTC-5", -5*3600), time.FixedZone("UTC+14", 14*3600)} {
+		time.Local = loc
+		t.Run(loc.String(), func(t *testing.T) {
+			w := &Workday{cache: maps.NewBit()}
+			//日k线的时间,交易所时区20241028 15:00
+			w.cache.Set(workdayKey(time.Date(2024, 10, 28, 15, 0, 0, 0, protocol.Location)), true)
+
+			//北京时间20241028的任意时刻都是工作日,不受服务器时区影响
+			for _, v := range []time.Time{
+				time.Date(2024, 10, 28, 0, 30, 0, 0, protocol.Location),
+				time.Date(2024, 10, 28, 23, 30, 0, 0, protocol.Location),
+				time.Date(2024, 10, 28, 9, 0, 0, 0, protocol.Location).In(time.Local),
+			} {
+				if !w.Is(v) {
+					t.Errorf("%s 预期是工作日", v)
+				}
+			}
+			if w.Is(time.Date(2024, 10, 27, 23, 59, 0, 0, protocol.Location)) {
+				t.Error("20241027 预期不是工作日")
+			}
+			if IntegerDay(time.Date(2024, 10, 27, 20, 0, 0, 0, time.UTC)).Format("20060102") != "20241028" {
+				t.Error("IntegerDay 应按交易所时区计算")
+			}
+		})
+	}
+}